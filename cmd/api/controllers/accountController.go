@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
+	"github.com/Mutay1/chat-backend/domain/repository"
+	helper "github.com/Mutay1/chat-backend/helpers"
+	"github.com/Mutay1/chat-backend/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+type ChangePasswordBody struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	Password        string `json:"password" validate:"required,min=6"`
+}
+
+type ChangeEmailBody struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// recordAudit records a security-sensitive action taken on the account of a user by the request.
+// The action has already happened, so a failure to record it is logged rather than returned.
+func recordAudit(ctx *gin.Context, app internal.Application, userId string, action string, details map[string]string) {
+	event := models.AuditEvent{
+		ID:        primitive.NewObjectID(),
+		UserID:    userId,
+		Action:    action,
+		IP:        ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+		Details:   details,
+	}
+	event.CreatedAt = time.Now().UTC().Truncate(time.Second)
+
+	logger := helper.Logger(ctx).With(zap.String("user_id", userId), zap.String("action", action))
+	if err := app.Repositories.Audit.Create(event); err != nil {
+		logger.Error("recording audit event", zap.Error(err))
+		return
+	}
+	logger.Info("audit")
+}
+
+// revokeOtherSessions signs the user out of every session but the one the request was made with,
+// disconnecting the sockets opened from them.
+func revokeOtherSessions(ctx *gin.Context, app internal.Application, userId string) error {
+	currentId := ctx.GetString("sid")
+	sessions, err := app.Repositories.Sessions.GetByUser(userId)
+	if err != nil {
+		return err
+	}
+
+	if err = app.Repositories.Sessions.DeleteByUser(userId, currentId); err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.ID.Hex() != currentId {
+			Manager.disconnect(app, userId, session.ID.Hex())
+		}
+	}
+
+	return nil
+}
+
+// ChangePassword sets a new password for the authenticated user once their current one is confirmed.
+func ChangePassword(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var body ChangePasswordBody
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
+		}
+
+		if err := validate.Struct(body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
+		}
+
+		user, err := app.Repositories.Users.GetById(ctx.GetString("uid"))
+		if err != nil {
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+
+		if passwordIsValid, _ := VerifyPassword(body.CurrentPassword, *user.Password); !passwordIsValid {
+			helper.AbortWithError(ctx, http.StatusForbidden, helper.ErrorInvalidCredentials, "the current password is incorrect")
+			return
+		}
+
+		if err = app.Repositories.Users.UpdatePassword(user.UserID, HashPassword(body.Password)); err != nil {
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+
+		if err = revokeOtherSessions(ctx, app, user.UserID); err != nil {
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+
+		recordAudit(ctx, app, user.UserID, models.AuditPasswordChanged, nil)
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": "Password successfully changed",
+		})
+	}
+}
+
+// ChangeEmail mails a verification link to the new email of the authenticated user once their password is confirmed.
+// The email is only changed when the link is opened.
+func ChangeEmail(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var body ChangeEmailBody
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
+		}
+
+		if err := validate.Struct(body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
+		}
+
+		user, err := app.Repositories.Users.GetById(ctx.GetString("uid"))
+		if err != nil {
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+
+		if passwordIsValid, _ := VerifyPassword(body.Password, *user.Password); !passwordIsValid {
+			helper.AbortWithError(ctx, http.StatusForbidden, helper.ErrorInvalidCredentials, "the password is incorrect")
+			return
+		}
+
+		if body.Email == *user.Email {
+			helper.AbortWithError(ctx, http.StatusUnprocessableEntity, helper.ErrorUnprocessable, "the email is already yours")
+			return
+		}
+
+		// the email is checked again once verified, in case it is taken meanwhile
+		_, err = app.Repositories.Users.GetByEmail(body.Email)
+		switch {
+		case err == nil:
+			helper.AbortWithError(ctx, http.StatusConflict, helper.ErrorConflict, "the email already exists")
+			return
+
+		case errors.Is(err, repository.ErrRecordNotFound):
+			// the email is free
+
+		default:
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+
+		latest, err := app.Repositories.Tokens.GetLatest(user.UserID, models.TokenPurposeEmailVerification)
+		switch {
+		case err == nil:
+			if throttleEmailVerification(ctx, latest) {
+				return
+			}
+
+		case !errors.Is(err, repository.ErrRecordNotFound):
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+
+		if err = sendEmailVerification(app, user, body.Email); err != nil {
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+
+		if err = revokeOtherSessions(ctx, app, user.UserID); err != nil {
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+
+		recordAudit(ctx, app, user.UserID, models.AuditEmailChangeRequested, map[string]string{"email": body.Email})
+
+		ctx.JSON(http.StatusAccepted, gin.H{
+			"message": "A verification link has been sent to the new email",
+		})
+	}
+}
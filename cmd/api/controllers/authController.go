@@ -6,6 +6,8 @@ import (
 	"github.com/Mutay1/chat-backend/cmd/api/internal"
 	"github.com/Mutay1/chat-backend/domain/repository"
 	"log"
+	"reflect"
+	"strings"
 
 	"net/http"
 	"time"
@@ -13,18 +15,37 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 
-	"github.com/Mutay1/chat-backend/database"
-
 	helper "github.com/Mutay1/chat-backend/helpers"
 	"github.com/Mutay1/chat-backend/models"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var userCollection = database.OpenCollection(database.Client, "user")
 var validate = validator.New()
 
+func init() {
+	// name fields after their JSON keys in validation errors, as clients know them by those
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+}
+
+type LoginBody struct {
+	Email      string `json:"email" validate:"required,email"`
+	Password   string `json:"password" validate:"required"`
+	DeviceName string `json:"deviceName" validate:"max=100"`
+}
+
+type RefreshTokenBody struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
 //HashPassword is used to encrypt the password before it is stored in the DB
 func HashPassword(password string) string {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
@@ -54,19 +75,13 @@ func SignUp(app internal.Application) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		// validate request
 		var user models.User
-		if err := ctx.BindJSON(&user); err != nil {
-			ctx.AbortWithStatusJSON(
-				http.StatusBadRequest,
-				gin.H{"error": err.Error()},
-			)
+		if err := ctx.ShouldBindJSON(&user); err != nil {
+			helper.HandleBindingError(ctx, err)
 			return
 		}
 
 		if err := validate.Struct(user); err != nil {
-			ctx.AbortWithStatusJSON(
-				http.StatusBadRequest,
-				gin.H{"error": err.Error()},
-			)
+			helper.HandleBindingError(ctx, err)
 			return
 		}
 
@@ -75,9 +90,8 @@ func SignUp(app internal.Application) gin.HandlerFunc {
 		user.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		user.ID = primitive.NewObjectID()
 		user.UserID = user.ID.Hex()
-		accessToken, refreshToken, _ := helper.GenerateTokens(app.Config.JwtSecret, user.UserID)
-		user.RefreshToken = &refreshToken
 		user.Status = "Hello There! Connect with me on Yarn!"
+		user.EmailVerified = false
 		password := HashPassword(*user.Password)
 		user.Password = &password
 
@@ -86,10 +100,7 @@ func SignUp(app internal.Application) gin.HandlerFunc {
 		if err != nil {
 			switch {
 			case errors.Is(err, repository.ErrDuplicateDetails):
-				ctx.AbortWithStatusJSON(
-					http.StatusUnprocessableEntity,
-					gin.H{"error": "the email or username already exists"},
-				)
+				helper.AbortWithError(ctx, http.StatusConflict, helper.ErrorConflict, "the email or username already exists")
 
 			default:
 				helper.HandleInternalServerError(ctx, err)
@@ -98,12 +109,24 @@ func SignUp(app internal.Application) gin.HandlerFunc {
 			return
 		}
 
+		accessToken, refreshToken, err := startSession(ctx, app, newUser.UserID, "")
+		if err != nil {
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+
+		// the account exists either way, so a failed email is left for the user to resend
+		if err = sendEmailVerification(app, newUser, *newUser.Email); err != nil {
+			helper.Logger(ctx).Error("sending verification email", zap.String("user_id", newUser.UserID), zap.Error(err))
+		}
+
 		h, _ := time.ParseDuration("24h")
 		ctx.JSON(http.StatusOK, gin.H{
 			"token":          accessToken,
 			"refreshToken":   refreshToken,
 			"expirationTime": h.Milliseconds(),
 			"userID":         newUser.UserID,
+			"emailVerified":  newUser.EmailVerified,
 			"profile": gin.H{
 				"city":      newUser.City,
 				"about":     newUser.About,
@@ -119,24 +142,23 @@ func SignUp(app internal.Application) gin.HandlerFunc {
 //Login authenticates a single user.
 func Login(app internal.Application) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		var user models.User
+		var body LoginBody
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
+		}
 
-		if err := ctx.BindJSON(&user); err != nil {
-			ctx.AbortWithStatusJSON(
-				http.StatusBadRequest,
-				gin.H{"error": err.Error()},
-			)
+		if err := validate.Struct(body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
 		}
 
 		// retrieve user from repository
-		foundUser, err := app.Repositories.Users.GetByEmail(*user.Email)
+		foundUser, err := app.Repositories.Users.GetByEmail(body.Email)
 		if err != nil {
 			switch {
 			case errors.Is(err, repository.ErrRecordNotFound):
-				ctx.AbortWithStatusJSON(
-					http.StatusUnprocessableEntity,
-					gin.H{"error": "invalid user credentials"},
-				)
+				helper.AbortWithError(ctx, http.StatusUnauthorized, helper.ErrorInvalidCredentials, "invalid user credentials")
 
 			default:
 				helper.HandleInternalServerError(ctx, err)
@@ -146,19 +168,15 @@ func Login(app internal.Application) gin.HandlerFunc {
 		}
 
 		// check password
-		passwordIsValid, msg := VerifyPassword(*user.Password, *foundUser.Password)
+		passwordIsValid, msg := VerifyPassword(body.Password, *foundUser.Password)
 		if passwordIsValid != true {
-			ctx.AbortWithStatusJSON(
-				http.StatusUnprocessableEntity,
-				gin.H{"error": msg},
-			)
+			helper.AbortWithError(ctx, http.StatusUnauthorized, helper.ErrorInvalidCredentials, msg)
 			return
 		}
 
-		// generate and update user tokens
-		accessToken, refreshToken, _ := helper.GenerateTokens(app.Config.JwtSecret, foundUser.UserID)
-
-		if err = app.Repositories.Users.UpdateRefreshToken(foundUser.UserID, refreshToken); err != nil {
+		// sign in on a new session
+		accessToken, refreshToken, err := startSession(ctx, app, foundUser.UserID, body.DeviceName)
+		if err != nil {
 			helper.HandleInternalServerError(ctx, err)
 			return
 		}
@@ -169,6 +187,7 @@ func Login(app internal.Application) gin.HandlerFunc {
 			"refreshToken":   refreshToken,
 			"expirationTime": h.Milliseconds(),
 			"userID":         foundUser.UserID,
+			"emailVerified":  foundUser.EmailVerified,
 			"profile": gin.H{
 				"city":      foundUser.City,
 				"about":     foundUser.About,
@@ -181,27 +200,37 @@ func Login(app internal.Application) gin.HandlerFunc {
 	}
 }
 
-// RefreshToken refreshes both the access and refresh tokens of a user.
+// RefreshToken refreshes both the access and refresh tokens of a session.
 func RefreshToken(app internal.Application) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		var user models.User
-		if err := ctx.BindJSON(&user); err != nil {
-			ctx.AbortWithStatusJSON(
-				http.StatusBadRequest,
-				gin.H{"error": err.Error()},
-			)
+		var body RefreshTokenBody
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
+		}
+
+		if err := validate.Struct(body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
+		}
+
+		claims, err := helper.ValidateRefreshToken(app.Config.JwtSecret, body.RefreshToken)
+		if err != nil {
+			helper.AbortWithError(ctx, http.StatusUnauthorized, helper.ErrorUnauthorized, "invalid or expired refresh token")
 			return
 		}
 
-		// retrieve user associated with refresh token
-		foundUser, err := app.Repositories.Users.GetByRefreshToken(*user.RefreshToken)
+		// retrieve the session the refresh token was issued to,
+		// tokens issued before sessions existed name none and can't be told apart, so they're rejected
+		hash := helper.HashToken(body.RefreshToken)
+		session, err := app.Repositories.Sessions.GetById(claims.SessionID)
+		if err == nil && (claims.SessionID == "" || session.UserID != claims.Subject) {
+			err = repository.ErrRecordNotFound
+		}
 		if err != nil {
 			switch {
 			case errors.Is(err, repository.ErrRecordNotFound):
-				ctx.AbortWithStatusJSON(
-					http.StatusUnprocessableEntity,
-					gin.H{"error": "invalid or expired refresh token"},
-				)
+				helper.AbortWithError(ctx, http.StatusUnauthorized, helper.ErrorUnauthorized, "invalid or expired refresh token")
 
 			default:
 				helper.HandleInternalServerError(ctx, err)
@@ -210,20 +239,42 @@ func RefreshToken(app internal.Application) gin.HandlerFunc {
 			return
 		}
 
-		// generate and update user tokens
-		accessToken, refreshToken, _ := helper.GenerateTokens(app.Config.JwtSecret, foundUser.UserID)
+		foundUser, err := app.Repositories.Users.GetById(session.UserID)
+		if err != nil {
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
 
-		if err = app.Repositories.Users.UpdateRefreshToken(foundUser.UserID, refreshToken); err != nil {
+		// rotate the refresh token, extending the session
+		accessToken, refreshToken, err := helper.GenerateTokens(app.Config.JwtSecret, foundUser.UserID, session.ID.Hex())
+		if err != nil {
 			helper.HandleInternalServerError(ctx, err)
 			return
 		}
 
+		now := time.Now().UTC().Truncate(time.Second)
+		err = app.Repositories.Sessions.Rotate(session.ID.Hex(), hash, helper.HashToken(refreshToken), now, now.Add(helper.RefreshTokenLifetime))
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrRecordNotFound):
+				// the token was issued to the session but already rotated, so whoever holds it may have stolen it
+				revokeReusedSession(ctx, app, session)
+				helper.AbortWithError(ctx, http.StatusUnauthorized, helper.ErrorUnauthorized, "refresh token reused, the session was revoked")
+
+			default:
+				helper.HandleInternalServerError(ctx, err)
+			}
+
+			return
+		}
+
 		h, _ := time.ParseDuration("24h")
 		ctx.JSON(http.StatusOK, gin.H{
 			"token":          accessToken,
 			"refreshToken":   refreshToken,
 			"expirationTime": h.Milliseconds(),
 			"userID":         foundUser.UserID,
+			"emailVerified":  foundUser.EmailVerified,
 			"profile": gin.H{
 				"city":      foundUser.City,
 				"about":     foundUser.About,
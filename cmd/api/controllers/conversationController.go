@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
+	"github.com/Mutay1/chat-backend/domain/repository"
+	helper "github.com/Mutay1/chat-backend/helpers"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultMessagesPageSize = 50
+	maxMessagesPageSize     = 100
+)
+
+// GetConversationMessages retrieves a page of the conversation between the signed in user and a friend.
+func GetConversationMessages(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// only accepted friends share a conversation
+		friendship, err := app.Repositories.Friendships.GetByPair(ctx.GetString("uid"), ctx.Param("friendId"))
+		if err == nil && !friendship.Accepted {
+			err = repository.ErrRecordNotFound
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrRecordNotFound):
+				helper.AbortWithError(ctx, http.StatusNotFound, helper.ErrorNotFound, "conversation not found")
+
+			default:
+				helper.HandleInternalServerError(ctx, err)
+			}
+
+			return
+		}
+
+		messagesPage(ctx, app, friendship.ID.Hex())
+	}
+}
+
+// messagesPage responds with a page of the conversation with the given id.
+// Pages run backwards from the "before" cursor, with the returned cursor pointing at the next older page.
+func messagesPage(ctx *gin.Context, app internal.Application, conversationId string) {
+	limit := defaultMessagesPageSize
+	if limitQuery := ctx.Query("limit"); limitQuery != "" {
+		parsedLimit, err := strconv.Atoi(limitQuery)
+		if err != nil || parsedLimit < 1 || parsedLimit > maxMessagesPageSize {
+			helper.AbortWithError(ctx, http.StatusBadRequest, helper.ErrorInvalidRequest, "limit must be between 1 and "+strconv.Itoa(maxMessagesPageSize))
+			return
+		}
+		limit = parsedLimit
+	}
+
+	before := ctx.Query("before")
+	if before != "" && !primitive.IsValidObjectID(before) {
+		helper.AbortWithError(ctx, http.StatusBadRequest, helper.ErrorInvalidRequest, "invalid cursor")
+		return
+	}
+
+	// fetch an extra message to know if an older page exists
+	messages, err := app.Repositories.Messages.GetByConversation(conversationId, before, int64(limit+1))
+	if err != nil {
+		helper.HandleInternalServerError(ctx, err)
+		return
+	}
+
+	var nextCursor *string
+	if len(messages) > limit {
+		messages = messages[1:]
+		cursor := messages[0].ID.Hex()
+		nextCursor = &cursor
+	}
+
+	receipts, err := app.Repositories.Receipts.GetByConversation(conversationId)
+	if err != nil {
+		helper.HandleInternalServerError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"messages":   messages,
+		"nextCursor": nextCursor,
+		"receipts":   receipts,
+	})
+}
+
+// unreadCount counts the messages of a conversation sent by others after the read cursor of the user.
+func unreadCount(app internal.Application, conversationId string, userId string) (int64, error) {
+	readUpTo := ""
+	receipt, err := app.Repositories.Receipts.GetByUser(conversationId, userId)
+	switch {
+	case err == nil:
+		if !receipt.ReadID.IsZero() {
+			readUpTo = receipt.ReadID.Hex()
+		}
+	case !errors.Is(err, repository.ErrRecordNotFound):
+		return 0, err
+	}
+
+	return app.Repositories.Messages.CountUnread(conversationId, userId, readUpTo)
+}
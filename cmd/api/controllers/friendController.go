@@ -1,66 +1,51 @@
 package controllers
 
 import (
-	"context"
 	"net/http"
-	"time"
 
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
+	helper "github.com/Mutay1/chat-backend/helpers"
 	"github.com/Mutay1/chat-backend/models"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func GetFriends() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		id, err := primitive.ObjectIDFromHex(c.GetString("uid"))
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid UserID"})
-			return
-		}
-		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		filter := bson.D{
-			{"$and",
-				bson.A{
-					bson.D{{"accepted", bson.D{{"$eq", true}}}},
-					bson.M{
-						"$or": []interface{}{
-							bson.M{"requester._id": id},
-							bson.M{"recipient._id": id},
-						},
-					},
-				},
-			},
-		}
-		cursor, err := friendshipCollection.Find(ctx, filter)
-		defer cancel()
+// GetFriends retrieves the other member of every accepted friendship of the signed in user.
+func GetFriends(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		uid := ctx.GetString("uid")
+
+		friendships, err := app.Repositories.Friendships.GetFriends(uid)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			helper.HandleInternalServerError(ctx, err)
 			return
 		}
-		var requestsLoaded []models.Friendship
-		var friendsLoaded []models.Friend
-		ID := c.GetString(("uid"))
-		if err = cursor.All(ctx, &requestsLoaded); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "An error occurred"})
-		}
-		for _, user := range requestsLoaded {
-			if user.Requester.ID.Hex() != ID {
 
-				if err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-					return
-				}
-				friendsLoaded = append(friendsLoaded, user.Requester)
-			} else {
-				if err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-					return
-				}
-				friendsLoaded = append(friendsLoaded, user.Recipient)
+		friends := []models.Friend{}
+		for _, friendship := range friendships {
+			friend := friendship.Recipient
+			if friendship.Requester.ID.Hex() != uid {
+				friend = friendship.Requester
 			}
+
+			// summarise the conversation instead of shipping its whole history
+			lastMessages, err := app.Repositories.Messages.GetByConversation(friendship.ID.Hex(), "", 1)
+			if err != nil {
+				helper.HandleInternalServerError(ctx, err)
+				return
+			}
+			if len(lastMessages) > 0 {
+				friend.LastMessage = &lastMessages[0]
+			}
+
+			friend.UnreadCount, err = unreadCount(app, friendship.ID.Hex(), uid)
+			if err != nil {
+				helper.HandleInternalServerError(ctx, err)
+				return
+			}
+
+			friends = append(friends, friend)
 		}
-		defer cancel()
-		c.JSON(http.StatusOK, friendsLoaded)
+
+		ctx.JSON(http.StatusOK, friends)
 	}
 }
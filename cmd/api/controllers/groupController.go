@@ -0,0 +1,442 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
+	"github.com/Mutay1/chat-backend/domain/repository"
+	helper "github.com/Mutay1/chat-backend/helpers"
+	"github.com/Mutay1/chat-backend/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type GroupBody struct {
+	Name    string   `json:"name" validate:"required,max=100"`
+	Members []string `json:"members"`
+}
+
+type GroupMemberBody struct {
+	UserID string `json:"userID" validate:"required"`
+}
+
+type GroupRoleBody struct {
+	Role string `json:"role" validate:"required,oneof=admin member"`
+}
+
+// leaveAttempts bounds how many times leaving a group is retried when it changes concurrently.
+const leaveAttempts = 3
+
+// groupRoleRanks orders the group roles by privilege.
+var groupRoleRanks = map[string]int{
+	models.GroupRoleOwner:  3,
+	models.GroupRoleAdmin:  2,
+	models.GroupRoleMember: 1,
+}
+
+// CreateGroup creates a group owned by the signed in user, with any of their friends as initial members.
+func CreateGroup(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		body := GroupBody{}
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
+		}
+
+		if err := validate.Struct(body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
+		}
+
+		uid := ctx.GetString("uid")
+		now := time.Now().UTC().Truncate(time.Second)
+		group := models.Group{
+			ID:        primitive.NewObjectID(),
+			Name:      body.Name,
+			Members:   []models.GroupMember{{UserID: uid, Role: models.GroupRoleOwner, JoinedAt: now}},
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+
+		for _, memberId := range body.Members {
+			if _, isMember := group.Member(memberId); isMember {
+				continue
+			}
+
+			if ok := requireFriend(ctx, app, uid, memberId); !ok {
+				return
+			}
+
+			group.Members = append(group.Members, models.GroupMember{
+				UserID:   memberId,
+				Role:     models.GroupRoleMember,
+				JoinedAt: now,
+			})
+		}
+
+		newGroup, err := app.Repositories.Groups.Create(group)
+		if err != nil {
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, newGroup)
+	}
+}
+
+// GetGroups retrieves the groups of the signed in user, each with a summary of its conversation.
+func GetGroups(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		uid := ctx.GetString("uid")
+
+		groups, err := app.Repositories.Groups.GetByMember(uid)
+		if err != nil {
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+
+		for index, group := range groups {
+			lastMessages, err := app.Repositories.Messages.GetByConversation(group.ID.Hex(), "", 1)
+			if err != nil {
+				helper.HandleInternalServerError(ctx, err)
+				return
+			}
+			if len(lastMessages) > 0 {
+				groups[index].LastMessage = &lastMessages[0]
+			}
+
+			groups[index].UnreadCount, err = unreadCount(app, group.ID.Hex(), uid)
+			if err != nil {
+				helper.HandleInternalServerError(ctx, err)
+				return
+			}
+		}
+
+		ctx.JSON(http.StatusOK, groups)
+	}
+}
+
+// GetGroup retrieves a group the signed in user is a member of.
+func GetGroup(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		group, _, ok := memberGroup(ctx, app)
+		if !ok {
+			return
+		}
+
+		ctx.JSON(http.StatusOK, group)
+	}
+}
+
+// UpdateGroup renames a group. Only owners and admins can update a group.
+func UpdateGroup(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		body := GroupBody{}
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
+		}
+
+		if err := validate.Struct(body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
+		}
+
+		// members are managed through their own routes
+		if body.Members != nil {
+			helper.AbortWithError(ctx, http.StatusBadRequest, helper.ErrorInvalidRequest, "members cannot be updated with the group")
+			return
+		}
+
+		group, member, ok := memberGroup(ctx, app)
+		if !ok || !requireManager(ctx, member) {
+			return
+		}
+
+		group.Name = body.Name
+		group.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+		if err := app.Repositories.Groups.UpdateDetails(group); err != nil {
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, group)
+	}
+}
+
+// UpdateGroupAvatar uploads a new avatar for a group. Only owners and admins can update a group.
+func UpdateGroupAvatar(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		group, member, ok := memberGroup(ctx, app)
+		if !ok || !requireManager(ctx, member) {
+			return
+		}
+
+		file, _, err := ctx.Request.FormFile("selectedFile")
+		if err != nil {
+			helper.AbortWithError(ctx, http.StatusBadRequest, helper.ErrorInvalidRequest, "no avatar provided")
+			return
+		}
+
+		// the group ID doubles as the avatar's public ID
+		fileTags := ctx.PostForm("tags")
+		uploadFile := uploadAvatar
+		if group.AvatarURL != "" {
+			uploadFile = updateAvatar
+		}
+
+		result, err := uploadFile(file, ctx, group.ID.Hex(), fileTags)
+		if err != nil {
+			helper.AbortWithError(ctx, http.StatusBadGateway, helper.ErrorUpstreamFailed, "avatar upload failed")
+			return
+		}
+
+		group.AvatarURL = result.SecureURL
+		group.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+		if err = app.Repositories.Groups.UpdateDetails(group); err != nil {
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, group)
+	}
+}
+
+// AddGroupMember adds a friend of the signed in user to a group. Only owners and admins can add members.
+func AddGroupMember(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		body := GroupMemberBody{}
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
+		}
+
+		if err := validate.Struct(body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
+		}
+
+		group, member, ok := memberGroup(ctx, app)
+		if !ok || !requireManager(ctx, member) {
+			return
+		}
+
+		if ok = requireFriend(ctx, app, member.UserID, body.UserID); !ok {
+			return
+		}
+
+		joinedAt := time.Now().UTC().Truncate(time.Second)
+		err := app.Repositories.Groups.AddMember(group.ID.Hex(), models.GroupMember{
+			UserID:   body.UserID,
+			Role:     models.GroupRoleMember,
+			JoinedAt: joinedAt,
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrDuplicateRecord):
+				helper.AbortWithError(ctx, http.StatusConflict, helper.ErrorConflict, "user is already a member")
+
+			default:
+				helper.HandleInternalServerError(ctx, err)
+			}
+
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": "Member successfully added",
+		})
+	}
+}
+
+// RemoveGroupMember removes a less privileged member from a group. Only owners and admins can remove members.
+func RemoveGroupMember(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		group, member, ok := memberGroup(ctx, app)
+		if !ok || !requireManager(ctx, member) {
+			return
+		}
+
+		target, ok := requireLesserMember(ctx, group, member)
+		if !ok {
+			return
+		}
+
+		if err := app.Repositories.Groups.RemoveMember(group.ID.Hex(), target.UserID); err != nil {
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": "Member successfully removed",
+		})
+	}
+}
+
+// UpdateGroupMemberRole promotes a member to admin or demotes an admin to member. Only owners can change roles.
+func UpdateGroupMemberRole(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		body := GroupRoleBody{}
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
+		}
+
+		if err := validate.Struct(body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
+		}
+
+		group, member, ok := memberGroup(ctx, app)
+		if !ok {
+			return
+		}
+
+		if member.Role != models.GroupRoleOwner {
+			helper.AbortWithError(ctx, http.StatusForbidden, helper.ErrorForbidden, "only the group owner can change roles")
+			return
+		}
+
+		target, ok := requireLesserMember(ctx, group, member)
+		if !ok {
+			return
+		}
+
+		if err := app.Repositories.Groups.UpdateMemberRole(group.ID.Hex(), target.UserID, body.Role); err != nil {
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": "Role successfully updated",
+		})
+	}
+}
+
+// LeaveGroup removes the signed in user from a group.
+// A leaving owner hands ownership to the longest-standing admin, or member if there are no admins,
+// and the group is deleted once its last member leaves.
+func LeaveGroup(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// the member leaves along with the group they read, and concurrent changes to it call for reading it again
+		for attempt := 0; attempt < leaveAttempts; attempt++ {
+			group, member, ok := memberGroup(ctx, app)
+			if !ok {
+				return
+			}
+
+			var err error
+			if len(group.Members) == 1 {
+				err = app.Repositories.Groups.Delete(group.ID.Hex())
+			} else {
+				var successor models.GroupMember
+				if member.Role == models.GroupRoleOwner {
+					for _, candidate := range group.Members {
+						if candidate.UserID == member.UserID {
+							continue
+						}
+						if successor.UserID == "" || groupRoleRanks[candidate.Role] > groupRoleRanks[successor.Role] {
+							successor = candidate
+						}
+					}
+				}
+
+				err = app.Repositories.Groups.Leave(group.ID.Hex(), member, successor.UserID)
+			}
+			if err != nil {
+				if errors.Is(err, repository.ErrRecordNotFound) {
+					continue
+				}
+
+				helper.HandleInternalServerError(ctx, err)
+				return
+			}
+
+			ctx.JSON(http.StatusOK, gin.H{
+				"message": "Group successfully left",
+			})
+			return
+		}
+
+		helper.AbortWithError(ctx, http.StatusConflict, helper.ErrorConflict, "the group changed while leaving it, please retry")
+	}
+}
+
+// GetGroupMessages retrieves a page of the conversation of a group the signed in user is a member of.
+func GetGroupMessages(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		group, _, ok := memberGroup(ctx, app)
+		if !ok {
+			return
+		}
+
+		messagesPage(ctx, app, group.ID.Hex())
+	}
+}
+
+// memberGroup retrieves the group in the route along with the signed in user's membership.
+// A not found response is sent if the group does not exist or the user is not a member.
+func memberGroup(ctx *gin.Context, app internal.Application) (models.Group, models.GroupMember, bool) {
+	group, err := app.Repositories.Groups.GetById(ctx.Param("groupId"))
+	if err != nil && !errors.Is(err, repository.ErrRecordNotFound) {
+		helper.HandleInternalServerError(ctx, err)
+		return models.Group{}, models.GroupMember{}, false
+	}
+
+	member, isMember := group.Member(ctx.GetString("uid"))
+	if err != nil || !isMember {
+		helper.AbortWithError(ctx, http.StatusNotFound, helper.ErrorNotFound, "group not found")
+		return models.Group{}, models.GroupMember{}, false
+	}
+
+	return group, member, true
+}
+
+// requireManager sends a forbidden response if the member is neither an owner nor an admin.
+func requireManager(ctx *gin.Context, member models.GroupMember) bool {
+	if groupRoleRanks[member.Role] < groupRoleRanks[models.GroupRoleAdmin] {
+		helper.AbortWithError(ctx, http.StatusForbidden, helper.ErrorForbidden, "only group owners and admins can do this")
+		return false
+	}
+
+	return true
+}
+
+// requireLesserMember retrieves the member in the route, ensuring they are less privileged than the acting member.
+func requireLesserMember(ctx *gin.Context, group models.Group, member models.GroupMember) (models.GroupMember, bool) {
+	target, isMember := group.Member(ctx.Param("userId"))
+	if !isMember {
+		helper.AbortWithError(ctx, http.StatusNotFound, helper.ErrorNotFound, "member not found")
+		return models.GroupMember{}, false
+	}
+
+	if groupRoleRanks[target.Role] >= groupRoleRanks[member.Role] {
+		helper.AbortWithError(ctx, http.StatusForbidden, helper.ErrorForbidden, "members can only be managed by someone more privileged")
+		return models.GroupMember{}, false
+	}
+
+	return target, true
+}
+
+// requireFriend sends an error response if both users are not accepted friends.
+func requireFriend(ctx *gin.Context, app internal.Application, userId string, friendId string) bool {
+	friendship, err := app.Repositories.Friendships.GetByPair(userId, friendId)
+	if err == nil && !friendship.Accepted {
+		err = repository.ErrRecordNotFound
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			helper.AbortWithError(ctx, http.StatusUnprocessableEntity, helper.ErrorUnprocessable, "only friends can be added to a group")
+
+		default:
+			helper.HandleInternalServerError(ctx, err)
+		}
+
+		return false
+	}
+
+	return true
+}
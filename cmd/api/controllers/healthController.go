@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
+	helper "github.com/Mutay1/chat-backend/helpers"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Healthz reports that the process is alive, without checking its dependencies.
+func Healthz() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+// Readyz reports whether the server can handle traffic, i.e. the database is reachable and the hub is running.
+// Failed checks are logged rather than returned, so their details stay private.
+func Readyz(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		checks := gin.H{"database": "ok", "hub": "ok"}
+		ready := true
+
+		if err := app.Repositories.Health.Ping(); err != nil {
+			helper.Logger(ctx).Error("database unavailable", zap.Error(err))
+			checks["database"] = "unavailable"
+			ready = false
+		}
+
+		if !Manager.Running() {
+			checks["hub"] = "unavailable"
+			ready = false
+		}
+
+		if !ready {
+			ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": checks})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"status": "ready", "checks": checks})
+	}
+}
+
+// Version reports the build of the running server.
+func Version(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{
+			"version":   app.Config.Version,
+			"commit":    app.Config.Commit,
+			"buildTime": app.Config.BuildTime,
+			"env":       app.Config.Env,
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
+	"github.com/Mutay1/chat-backend/domain/mailer"
+	"github.com/Mutay1/chat-backend/domain/repository"
+	helper "github.com/Mutay1/chat-backend/helpers"
+	"github.com/Mutay1/chat-backend/models"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// passwordResetLifetime is how long a password reset link can be used for
+	passwordResetLifetime = time.Hour
+	// passwordResetResendInterval is how long a user has to wait before being sent another reset link
+	passwordResetResendInterval = time.Minute
+)
+
+type ForgotPasswordBody struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordBody struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=6"`
+}
+
+// sendPasswordReset mails the user a link to reset their password, revoking any link sent before.
+// Nothing is sent if a link was sent too recently.
+func sendPasswordReset(app internal.Application, user models.User) error {
+	latest, err := app.Repositories.Tokens.GetLatest(user.UserID, models.TokenPurposePasswordReset)
+	switch {
+	case err == nil:
+		if time.Since(latest.CreatedAt) < passwordResetResendInterval {
+			return nil
+		}
+
+	case errors.Is(err, repository.ErrRecordNotFound):
+		// no link was sent yet
+
+	default:
+		return err
+	}
+
+	token, err := issueOneTimeToken(app, user, models.TokenPurposePasswordReset, *user.Email, passwordResetLifetime)
+	if err != nil {
+		return err
+	}
+
+	return app.Mailer.Send(mailer.Message{
+		To:      *user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nChoose a new password by opening the link below within an hour:\n\n%s\n\nIf you did not ask to reset your password, you can ignore this email.\n",
+			*user.Username, clientLink(app, "reset-password", token),
+		),
+	})
+}
+
+// ForgotPassword mails a password reset link to the given email if it belongs to a user.
+// The response is the same whether it does or not, so it can't be used to find registered emails.
+func ForgotPassword(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var body ForgotPasswordBody
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
+		}
+
+		if err := validate.Struct(body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
+		}
+
+		user, err := app.Repositories.Users.GetByEmail(body.Email)
+		switch {
+		case err == nil:
+			// mail in the background, as the time taken to send would give registered emails away
+			logger := helper.Logger(ctx)
+			go func() {
+				if err := sendPasswordReset(app, user); err != nil {
+					logger.Error("sending password reset email", zap.String("user_id", user.UserID), zap.Error(err))
+				}
+			}()
+
+		case errors.Is(err, repository.ErrRecordNotFound):
+			// answered as if the email was registered
+
+		default:
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": "If the email is registered, a password reset link has been sent to it",
+		})
+	}
+}
+
+// ResetPassword sets a new password with the token of a password reset link, signing the user out everywhere.
+func ResetPassword(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var body ResetPasswordBody
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
+		}
+
+		if err := validate.Struct(body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
+		}
+
+		// tokens are single use, so consume it before anything else
+		token, err := app.Repositories.Tokens.Consume(models.TokenPurposePasswordReset, helper.HashToken(body.Token))
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrRecordNotFound):
+				helper.AbortWithError(ctx, http.StatusBadRequest, helper.ErrorInvalidToken, "invalid or expired password reset token")
+
+			default:
+				helper.HandleInternalServerError(ctx, err)
+			}
+
+			return
+		}
+
+		if err = app.Repositories.Users.UpdatePassword(token.UserID, HashPassword(body.Password)); err != nil {
+			switch {
+			case errors.Is(err, repository.ErrRecordNotFound):
+				helper.AbortWithError(ctx, http.StatusBadRequest, helper.ErrorInvalidToken, "invalid or expired password reset token")
+
+			default:
+				helper.HandleInternalServerError(ctx, err)
+			}
+
+			return
+		}
+
+		// whoever knew the old password may still be signed in
+		if err = app.Repositories.Sessions.DeleteByUser(token.UserID, ""); err != nil {
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+		Manager.disconnect(app, token.UserID, "")
+
+		recordAudit(ctx, app, token.UserID, models.AuditPasswordReset, nil)
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": "Password successfully reset",
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
+	helper "github.com/Mutay1/chat-backend/helpers"
+	"github.com/Mutay1/chat-backend/models"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// friendIds retrieves the IDs of the accepted friends of a user.
+func friendIds(app internal.Application, userId string) ([]string, error) {
+	friendships, err := app.Repositories.Friendships.GetFriends(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, friendship := range friendships {
+		if friendship.Requester.ID.Hex() != userId {
+			ids = append(ids, friendship.Requester.ID.Hex())
+		} else {
+			ids = append(ids, friendship.Recipient.ID.Hex())
+		}
+	}
+
+	return ids, nil
+}
+
+// broadcastPresence tells the friends of a user about their presence.
+func (manager *ClientManager) broadcastPresence(app internal.Application, presence models.Presence) {
+	friends, err := friendIds(app, presence.UserID)
+	if err != nil {
+		app.Logger.Error("listing friends", zap.String("user_id", presence.UserID), zap.Error(err))
+		return
+	}
+
+	manager.send(app, friends, encodeFrame(models.EventPresence, "", presence))
+}
+
+// GetFriendsPresence retrieves the presence of every accepted friend of the signed in user.
+func GetFriendsPresence(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		friends, err := friendIds(app, ctx.GetString("uid"))
+		if err != nil {
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+
+		presences, err := app.Presence.Get(friends)
+		if err != nil {
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, presences)
+	}
+}
@@ -2,21 +2,17 @@ package controllers
 
 import (
 	"context"
-	"fmt"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
 	helper "github.com/Mutay1/chat-backend/helpers"
-	"github.com/Mutay1/chat-backend/models"
 	"github.com/cloudinary/cloudinary-go"
 	"github.com/cloudinary/cloudinary-go/api/uploader"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var cloudName string = os.Getenv("CLOUDINARY_CLOUD_NAME")
@@ -40,7 +36,6 @@ func deleteAvatar(ctx context.Context, uid string) (*uploader.DestroyResult, err
 	result, err := cld.Upload.Destroy(ctx, uploader.DestroyParams{
 		PublicID: uid,
 	})
-	fmt.Println("DELETED")
 	return result, err
 }
 
@@ -59,129 +54,71 @@ func updateAvatar(file multipart.File, ctx context.Context, uid string, fileTags
 }
 
 //UpdateProfile is used to update status, about and avatar
-func UpdateProfile() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var foundUser models.User
-		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		err := userCollection.FindOne(ctx, bson.M{"email": c.GetString("email")}).Decode(&foundUser)
-		defer cancel()
+func UpdateProfile(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		foundUser, err := app.Repositories.Users.GetById(ctx.GetString("uid"))
 		if err != nil {
-			fmt.Println(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User"})
+			helper.HandleInternalServerError(ctx, err)
 			return
 		}
-		fileTags := c.PostForm("tags")
-		status := c.PostForm("status")
-		city := c.PostForm("city")
-		about := c.PostForm("about")
-		file, _, err := c.Request.FormFile("selectedFile")
-		var result *uploader.UploadResult
-		var updateObj primitive.D
-		var secureURL string
+
+		fileTags := ctx.PostForm("tags")
+		foundUser.Status = ctx.PostForm("status")
+		foundUser.City = ctx.PostForm("city")
+		foundUser.About = ctx.PostForm("about")
+
+		// replace the avatar only if a new one was uploaded
+		file, _, err := ctx.Request.FormFile("selectedFile")
 		if err == nil {
+			var result *uploader.UploadResult
 			if foundUser.AvatarURL != "" {
-				result, err = updateAvatar(file, c, c.GetString("uid"), fileTags)
+				result, err = updateAvatar(file, ctx, foundUser.UserID, fileTags)
 			} else {
-				result, err = uploadAvatar(file, c, c.GetString("uid"), fileTags)
+				result, err = uploadAvatar(file, ctx, foundUser.UserID, fileTags)
 			}
 			if err != nil {
-				c.String(http.StatusConflict, "Upload to cloudinary failed")
+				helper.AbortWithError(ctx, http.StatusBadGateway, helper.ErrorUpstreamFailed, "avatar upload failed")
+				return
 			}
-			secureURL = result.SecureURL
-		}
-		updateObj = append(updateObj, bson.E{"avatarURL", secureURL})
-		updateObj = append(updateObj, bson.E{"about", about})
-		updateObj = append(updateObj, bson.E{"status", status})
-		updateObj = append(updateObj, bson.E{"city", city})
-
-		UpdatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		updateObj = append(updateObj, bson.E{"updatedAt", UpdatedAt})
-
-		upsert := true
-		filter := bson.M{"email": foundUser.Email}
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
+			foundUser.AvatarURL = result.SecureURL
 		}
 
-		_, err = userCollection.UpdateOne(
-			ctx,
-			filter,
-			bson.D{
-				{"$set", updateObj},
-			},
-			&opt,
-		)
-
-		id, err := primitive.ObjectIDFromHex(foundUser.UserID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err})
-			return
-		}
-		var friend models.Friend
-		err = userCollection.FindOne(ctx, bson.M{"email": c.GetString("email")}).Decode(&friend)
-		defer cancel()
-		if err != nil {
-			fmt.Println(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User"})
-			return
-		}
-		data, err := helper.ToDoc(friend)
-		_, err = friendshipCollection.UpdateMany(
-			ctx,
-			bson.M{"requester._id": id},
-			bson.D{{"$set",
-				bson.D{
-					{"requester", data},
-				},
-			}},
-		)
+		foundUser.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updatedUser, err := app.Repositories.Users.UpdateProfile(foundUser)
 		if err != nil {
-			fmt.Println(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+			helper.HandleInternalServerError(ctx, err)
 			return
 		}
 
-		_, err = friendshipCollection.UpdateMany(
-			ctx,
-			bson.M{"recipient._id": id},
-			bson.D{{"$set",
-				bson.D{
-					{"recipient", data},
-				},
-			}},
-		)
-		if err != nil {
-			fmt.Println(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		// keep the details embedded in friendships in sync
+		if err = app.Repositories.Friendships.UpdateMember(friendFromUser(updatedUser)); err != nil {
+			helper.HandleInternalServerError(ctx, err)
 			return
 		}
-		defer cancel()
 
-		c.JSON(http.StatusCreated, gin.H{
+		ctx.JSON(http.StatusCreated, gin.H{
 			"message": "Successfully uploaded the file",
 		})
 	}
 }
 
 //GetProfile returns user Profile
-func GetProfile() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var foundUser models.User
-		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		err := userCollection.FindOne(ctx, bson.M{"email": c.GetString("email")}).Decode(&foundUser)
-		defer cancel()
+func GetProfile(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		foundUser, err := app.Repositories.Users.GetById(ctx.GetString("uid"))
 		if err != nil {
-			fmt.Println(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User"})
+			helper.HandleInternalServerError(ctx, err)
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{
-			"city":      foundUser.City,
-			"about":     foundUser.About,
-			"status":    foundUser.Status,
-			"firstName": foundUser.FirstName,
-			"lastName":  foundUser.LastName,
-			"avatar":    foundUser.AvatarURL,
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"city":          foundUser.City,
+			"about":         foundUser.About,
+			"status":        foundUser.Status,
+			"firstName":     foundUser.FirstName,
+			"lastName":      foundUser.LastName,
+			"avatar":        foundUser.AvatarURL,
+			"emailVerified": foundUser.EmailVerified,
 		})
 	}
 }
@@ -1,224 +1,197 @@
 package controllers
 
 import (
-	"context"
-	"fmt"
-	"io/ioutil"
+	"errors"
 	"net/http"
 	"time"
 
-	"github.com/Mutay1/chat-backend/database"
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
+	"github.com/Mutay1/chat-backend/domain/repository"
+	helper "github.com/Mutay1/chat-backend/helpers"
 	"github.com/Mutay1/chat-backend/models"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-var friendshipCollection *mongo.Collection = database.OpenCollection(database.Client, "friendships")
-
 type Body struct {
-	Username string `json:"username"`
+	Username string `json:"username" validate:"required"`
 }
 
 type RequestBody struct {
-	ID string
+	ID string `validate:"required"`
 }
 
 //SendRequest generates a Friend Request
-func SendRequest() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var request models.Friendship
-		var requester models.Friend
-		var recipient models.Friend
-		var messages []models.Message
+func SendRequest(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
 		body := Body{}
-		if err := c.BindJSON(&body); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			helper.HandleBindingError(ctx, err)
 			return
 		}
-		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		err := userCollection.FindOne(ctx, bson.M{"email": c.GetString("email")}).Decode(&requester)
-		defer cancel()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid Username"})
+
+		if err := validate.Struct(body); err != nil {
+			helper.HandleBindingError(ctx, err)
 			return
 		}
-		err = userCollection.FindOne(ctx, bson.M{"username": body.Username}).Decode(&recipient)
-		defer cancel()
+
+		// retrieve both members of the request
+		requester, err := app.Repositories.Users.GetById(ctx.GetString("uid"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid Username"})
+			helper.HandleInternalServerError(ctx, err)
 			return
 		}
-		filter := bson.D{
-			{"$or",
-				bson.A{
-					bson.M{
-						"$and": []interface{}{
-							bson.M{"requester.username": recipient.Username},
-							bson.M{"recipient.username": requester.Username},
-						},
-					},
-					bson.M{
-						"$and": []interface{}{
-							bson.M{"requester.username": requester.Username},
-							bson.M{"recipient.username": recipient.Username},
-						},
-					},
-				},
-			},
-		}
-		count, err := friendshipCollection.CountDocuments(ctx, filter)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while checking for the phone number"})
+
+		// stop throwaway accounts from spamming requests
+		if !requester.EmailVerified {
+			helper.AbortWithError(ctx, http.StatusForbidden, helper.ErrorEmailNotVerified, "verify your email before sending friend requests")
 			return
 		}
-		if count > 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Request already sent"})
+
+		recipient, err := app.Repositories.Users.GetByUsername(body.Username)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrRecordNotFound):
+				helper.AbortWithError(ctx, http.StatusNotFound, helper.ErrorNotFound, "user not found")
+
+			default:
+				helper.HandleInternalServerError(ctx, err)
+			}
+
 			return
 		}
-		if recipient.ID == requester.ID {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "You can't send friend request to yourself."})
+
+		if recipient.UserID == requester.UserID {
+			helper.AbortWithError(ctx, http.StatusUnprocessableEntity, helper.ErrorUnprocessable, "you can't send a friend request to yourself")
 			return
 		}
 
-		requester.Messages = messages
-		recipient.Messages = messages
-		request.ID = primitive.NewObjectID()
-		request.Recipient = recipient
-		request.Requester = requester
+		// create new request in repository
+		request := models.Friendship{
+			ID:        primitive.NewObjectID(),
+			Requester: friendFromUser(requester),
+			Recipient: friendFromUser(recipient),
+		}
 		request.CreatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		request.UpdatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		resultInsertionNumber, insertErr := friendshipCollection.InsertOne(ctx, request)
 
-		if insertErr != nil {
-			msg := fmt.Sprintf("Request item was not created")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		newRequest, err := app.Repositories.Friendships.Create(request)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrDuplicateRecord):
+				helper.AbortWithError(ctx, http.StatusConflict, helper.ErrorConflict, "request already sent")
+
+			default:
+				helper.HandleInternalServerError(ctx, err)
+			}
+
 			return
 		}
-		defer cancel()
 
-		c.JSON(http.StatusOK, resultInsertionNumber)
+		ctx.JSON(http.StatusOK, newRequest)
 	}
 }
 
 //GetSentRequest retrieves all requests sent by signed in user
-func GetSentRequest() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		id, err := primitive.ObjectIDFromHex(c.GetString("uid"))
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid UserID"})
-			return
-		}
-		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		filter := bson.D{
-			{"$and",
-				bson.A{
-					bson.D{{"accepted", bson.D{{"$eq", false}}}},
-					bson.D{{"requester._id", id}},
-				},
-			},
-		}
-		cursor, err := friendshipCollection.Find(ctx, filter)
-		defer cancel()
+func GetSentRequest(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requests, err := app.Repositories.Friendships.GetSent(ctx.GetString("uid"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid Username"})
+			helper.HandleInternalServerError(ctx, err)
 			return
 		}
-		var requestsLoaded []bson.M
-		if err = cursor.All(ctx, &requestsLoaded); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "An error occurred"})
-		}
-		defer cancel()
-		c.JSON(http.StatusOK, requestsLoaded)
+
+		ctx.JSON(http.StatusOK, requests)
 	}
 }
 
 //GetReceivedRequest retrieves all requests received by signed in user
-func GetReceivedRequest() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		id, err := primitive.ObjectIDFromHex(c.GetString("uid"))
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid UserID"})
-			return
-		}
-		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		filter := bson.D{
-			{"$and",
-				bson.A{
-					bson.D{{"accepted", bson.D{{"$eq", false}}}},
-					bson.D{{"recipient._id", id}},
-				},
-			},
-		}
-		cursor, err := friendshipCollection.Find(ctx, filter)
-		defer cancel()
+func GetReceivedRequest(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requests, err := app.Repositories.Friendships.GetReceived(ctx.GetString("uid"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid Username"})
+			helper.HandleInternalServerError(ctx, err)
 			return
 		}
-		var requestsLoaded []bson.M
-		if err = cursor.All(ctx, &requestsLoaded); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "An error occurred"})
-		}
-		defer cancel()
-		c.JSON(http.StatusOK, requestsLoaded)
+
+		ctx.JSON(http.StatusOK, requests)
 	}
 }
 
-func AcceptRequest() gin.HandlerFunc {
-	return func(c *gin.Context) {
+// AcceptRequest accepts a pending request received by the signed in user.
+func AcceptRequest(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
 		body := RequestBody{}
-		if err := c.BindJSON(&body); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			helper.HandleBindingError(ctx, err)
 			return
 		}
-		fmt.Println(body)
-		jsonData, err := ioutil.ReadAll(c.Request.Body)
-		if err != nil {
-			// Handle error
+
+		if err := validate.Struct(body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
 		}
-		fmt.Println((jsonData))
-		id, err := primitive.ObjectIDFromHex(body.ID)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+
+		if err := app.Repositories.Friendships.Accept(body.ID, ctx.GetString("uid")); err != nil {
+			switch {
+			case errors.Is(err, repository.ErrRecordNotFound):
+				helper.AbortWithError(ctx, http.StatusNotFound, helper.ErrorNotFound, "request not found")
+
+			default:
+				helper.HandleInternalServerError(ctx, err)
+			}
+
 			return
 		}
-		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		_, err = friendshipCollection.UpdateOne(
-			ctx,
-			bson.M{"_id": id},
-			bson.D{{"$set",
-				bson.D{
-					{"accepted", true},
-				},
-			}},
-		)
-		defer cancel()
-		c.JSON(http.StatusOK, gin.H{
+
+		ctx.JSON(http.StatusOK, gin.H{
 			"message": "Request successfully accepted",
 		})
 	}
 }
 
-func DeleteRequest() gin.HandlerFunc {
-	return func(c *gin.Context) {
-
+// DeleteRequest declines a received request or cancels a sent one.
+func DeleteRequest(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
 		body := RequestBody{}
-		if err := c.BindJSON(&body); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			helper.HandleBindingError(ctx, err)
 			return
 		}
-		id, err := primitive.ObjectIDFromHex(string(body.ID))
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+
+		if err := validate.Struct(body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
+		}
+
+		if err := app.Repositories.Friendships.Decline(body.ID, ctx.GetString("uid")); err != nil {
+			switch {
+			case errors.Is(err, repository.ErrRecordNotFound):
+				helper.AbortWithError(ctx, http.StatusNotFound, helper.ErrorNotFound, "request not found")
+
+			default:
+				helper.HandleInternalServerError(ctx, err)
+			}
+
 			return
 		}
-		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		_, err = friendshipCollection.DeleteOne(
-			ctx,
-			bson.M{"_id": id},
-		)
-		defer cancel()
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": "Request successfully deleted",
+		})
+	}
+}
+
+// friendFromUser extracts the public details of a user for embedding in a friendship.
+func friendFromUser(user models.User) models.Friend {
+	return models.Friend{
+		ID:        user.ID,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Username:  user.Username,
+		AvatarURL: user.AvatarURL,
+		Status:    user.Status,
+		About:     user.About,
+		City:      user.City,
 	}
 }
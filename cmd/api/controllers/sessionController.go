@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
+	"github.com/Mutay1/chat-backend/domain/repository"
+	helper "github.com/Mutay1/chat-backend/helpers"
+	"github.com/Mutay1/chat-backend/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// SessionResponse is a session as listed to its user, flagging the one the request was made with.
+type SessionResponse struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// startSession signs the user in on the client making the request, returning the tokens of the new session.
+func startSession(ctx *gin.Context, app internal.Application, userId string, deviceName string) (accessToken string, refreshToken string, err error) {
+	id := primitive.NewObjectID()
+	accessToken, refreshToken, err = helper.GenerateTokens(app.Config.JwtSecret, userId, id.Hex())
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	_, err = app.Repositories.Sessions.Create(models.Session{
+		ID:               id,
+		UserID:           userId,
+		RefreshTokenHash: helper.HashToken(refreshToken),
+		DeviceName:       deviceName,
+		UserAgent:        ctx.Request.UserAgent(),
+		IP:               ctx.ClientIP(),
+		CreatedAt:        now,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(helper.RefreshTokenLifetime),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+// revokeReusedSession revokes the session a rotated refresh token was presented for, and disconnects every socket of its user,
+// as either the user or whoever stole the token from them now holds a token that will be refused.
+func revokeReusedSession(ctx *gin.Context, app internal.Application, session models.Session) {
+	helper.Logger(ctx).Warn("refresh token reused", zap.String("user_id", session.UserID), zap.String("session_id", session.ID.Hex()))
+
+	if err := app.Repositories.Sessions.Delete(session.UserID, session.ID.Hex()); err != nil && !errors.Is(err, repository.ErrRecordNotFound) {
+		helper.Logger(ctx).Error("revoking session", zap.String("session_id", session.ID.Hex()), zap.Error(err))
+	}
+	Manager.disconnect(app, session.UserID, "")
+
+	recordAudit(ctx, app, session.UserID, models.AuditRefreshTokenReused, map[string]string{"sessionID": session.ID.Hex()})
+}
+
+// GetSessions lists the devices the authenticated user is signed in on.
+func GetSessions(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		sessions, err := app.Repositories.Sessions.GetByUser(ctx.GetString("uid"))
+		if err != nil {
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+
+		response := make([]SessionResponse, 0, len(sessions))
+		for _, session := range sessions {
+			response = append(response, SessionResponse{
+				Session: session,
+				Current: session.ID.Hex() == ctx.GetString("sid"),
+			})
+		}
+
+		ctx.JSON(http.StatusOK, response)
+	}
+}
+
+// RevokeSession signs the authenticated user out of one of their sessions.
+func RevokeSession(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		err := app.Repositories.Sessions.Delete(ctx.GetString("uid"), ctx.Param("id"))
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrRecordNotFound):
+				helper.AbortWithError(ctx, http.StatusNotFound, helper.ErrorNotFound, "session not found")
+
+			default:
+				helper.HandleInternalServerError(ctx, err)
+			}
+
+			return
+		}
+		Manager.disconnect(app, ctx.GetString("uid"), ctx.Param("id"))
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": "Session successfully revoked",
+		})
+	}
+}
+
+// Logout signs the authenticated user out of the session the request was made with.
+func Logout(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// tokens issued before sessions existed have no session to revoke
+		if sessionId := ctx.GetString("sid"); sessionId != "" {
+			err := app.Repositories.Sessions.Delete(ctx.GetString("uid"), sessionId)
+			if err != nil && !errors.Is(err, repository.ErrRecordNotFound) {
+				helper.HandleInternalServerError(ctx, err)
+				return
+			}
+			Manager.disconnect(app, ctx.GetString("uid"), sessionId)
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": "Successfully logged out",
+		})
+	}
+}
+
+// LogoutEverywhere signs the authenticated user out of all their sessions, including the current one.
+func LogoutEverywhere(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if err := app.Repositories.Sessions.DeleteByUser(ctx.GetString("uid"), ""); err != nil {
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+		Manager.disconnect(app, ctx.GetString("uid"), "")
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": "Successfully logged out everywhere",
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
+	"github.com/Mutay1/chat-backend/domain/mailer"
+	"github.com/Mutay1/chat-backend/domain/repository"
+	helper "github.com/Mutay1/chat-backend/helpers"
+	"github.com/Mutay1/chat-backend/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// emailVerificationLifetime is how long a verification link can be used for
+	emailVerificationLifetime = 24 * time.Hour
+	// emailVerificationResendInterval is how long a user has to wait before asking for another link
+	emailVerificationResendInterval = time.Minute
+)
+
+type VerifyEmailBody struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// issueOneTimeToken stores a new token sent to the email for the purpose, revoking any issued before.
+func issueOneTimeToken(app internal.Application, user models.User, purpose string, email string, lifetime time.Duration) (string, error) {
+	token, hash, err := helper.GenerateOneTimeToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	_, err = app.Repositories.Tokens.Create(models.OneTimeToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.UserID,
+		Purpose:   purpose,
+		Hash:      hash,
+		Email:     email,
+		ExpiresAt: now.Add(lifetime),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// clientLink returns the link to the given page of the web client, carrying the token.
+func clientLink(app internal.Application, page string, token string) string {
+	return fmt.Sprintf("%s/%s?token=%s", strings.TrimRight(app.Config.AppUrl, "/"), page, token)
+}
+
+// sendEmailVerification mails the user a link confirming they own the given email,
+// revoking any link sent before.
+func sendEmailVerification(app internal.Application, user models.User, email string) error {
+	token, err := issueOneTimeToken(app, user, models.TokenPurposeEmailVerification, email, emailVerificationLifetime)
+	if err != nil {
+		return err
+	}
+
+	return app.Mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nConfirm this is your email address by opening the link below within 24 hours:\n\n%s\n\nOr enter this code in the app: %s\n\nIf you did not sign up, you can ignore this email.\n",
+			*user.Username, clientLink(app, "verify-email", token), token,
+		),
+	})
+}
+
+// throttleEmailVerification aborts the request if the latest verification email was sent too recently,
+// so verification emails can't be used to flood an inbox. It reports whether the request was aborted.
+func throttleEmailVerification(ctx *gin.Context, latest models.OneTimeToken) bool {
+	wait := latest.CreatedAt.Add(emailVerificationResendInterval).Sub(time.Now())
+	if wait <= 0 {
+		return false
+	}
+
+	ctx.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+	helper.AbortWithError(ctx, http.StatusTooManyRequests, helper.ErrorTooManyRequests, "a verification email was sent recently, try again later")
+	return true
+}
+
+// VerifyEmail confirms the email a verification link was sent to.
+func VerifyEmail(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var body VerifyEmailBody
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
+		}
+
+		if err := validate.Struct(body); err != nil {
+			helper.HandleBindingError(ctx, err)
+			return
+		}
+
+		// tokens are single use, so consume it before anything else
+		token, err := app.Repositories.Tokens.Consume(models.TokenPurposeEmailVerification, helper.HashToken(body.Token))
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrRecordNotFound):
+				helper.AbortWithError(ctx, http.StatusBadRequest, helper.ErrorInvalidToken, "invalid or expired verification token")
+
+			default:
+				helper.HandleInternalServerError(ctx, err)
+			}
+
+			return
+		}
+
+		user, err := app.Repositories.Users.GetById(token.UserID)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrRecordNotFound):
+				helper.AbortWithError(ctx, http.StatusBadRequest, helper.ErrorInvalidToken, "invalid or expired verification token")
+
+			default:
+				helper.HandleInternalServerError(ctx, err)
+			}
+
+			return
+		}
+
+		if err = app.Repositories.Users.VerifyEmail(token.UserID, token.Email); err != nil {
+			switch {
+			case errors.Is(err, repository.ErrDuplicateDetails):
+				helper.AbortWithError(ctx, http.StatusConflict, helper.ErrorConflict, "the email already exists")
+
+			case errors.Is(err, repository.ErrRecordNotFound):
+				helper.AbortWithError(ctx, http.StatusBadRequest, helper.ErrorInvalidToken, "invalid or expired verification token")
+
+			default:
+				helper.HandleInternalServerError(ctx, err)
+			}
+
+			return
+		}
+
+		// verifying a new email completes its change
+		if *user.Email != token.Email {
+			recordAudit(ctx, app, user.UserID, models.AuditEmailChanged, map[string]string{"previousEmail": *user.Email, "email": token.Email})
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"message":       "Email successfully verified",
+			"email":         token.Email,
+			"emailVerified": true,
+		})
+	}
+}
+
+// ResendEmailVerification mails the authenticated user a new verification link, at most once a minute.
+// The link is sent to the email they are changing to if a change is pending.
+func ResendEmailVerification(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, err := app.Repositories.Users.GetById(ctx.GetString("uid"))
+		if err != nil {
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+
+		latest, err := app.Repositories.Tokens.GetLatest(user.UserID, models.TokenPurposeEmailVerification)
+		found := err == nil
+		if err != nil && !errors.Is(err, repository.ErrRecordNotFound) {
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+
+		email := *user.Email
+		if found && latest.ExpiresAt.After(time.Now()) {
+			email = latest.Email
+		} else if user.EmailVerified {
+			helper.AbortWithError(ctx, http.StatusConflict, helper.ErrorConflict, "the email is already verified")
+			return
+		}
+
+		if found && throttleEmailVerification(ctx, latest) {
+			return
+		}
+
+		if err = sendEmailVerification(app, user, email); err != nil {
+			helper.HandleInternalServerError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": "Verification email successfully sent",
+		})
+	}
+}
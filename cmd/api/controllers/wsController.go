@@ -1,157 +1,448 @@
 package controllers
 
 import (
+	"bytes"
 	"context"
+	"encoding/hex"
 	"encoding/json"
-	"fmt"
-	"log"
+	"errors"
+	"hash/fnv"
+	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
+	"github.com/Mutay1/chat-backend/domain/repository"
 	helper "github.com/Mutay1/chat-backend/helpers"
+	"github.com/Mutay1/chat-backend/infrastructure/metrics"
 	"github.com/Mutay1/chat-backend/models"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/x/mongo/driver/uuid"
+	"go.uber.org/zap"
+)
+
+// sendQueueSize is how many frames may wait for a client to write them before it is evicted as a slow consumer.
+const sendQueueSize = 256
+
+// Reasons sockets are evicted for.
+const (
+	evictionSlowConsumer = "slow_consumer"
+	evictionPongTimeout  = "pong_timeout"
 )
 
 // ClientManager is a websocket manager
+// Frames sent by clients are handled on their own goroutine, so the hub loop only hands the frames
+// received from the broker over to the queues of the sockets connected to this node.
 type ClientManager struct {
-	Clients    map[string][]*Client
-	Broadcast  chan []byte
-	Register   chan *Client
-	Unregister chan *Client
+	mu      sync.RWMutex
+	clients map[string][]*Client
+
+	// subscribers serialize the subscriptions of each user, see register
+	subscribers [64]sync.Mutex
+	// sequencers serialize storing and publishing the events of each user, see publish
+	sequencers [64]sync.Mutex
+
+	// handlers tracks the frames being handled, so shutdown can wait for them before the repositories close
+	handlers sync.WaitGroup
+	// stopping is set under mu once shutdown starts, after which no more frames are handled
+	stopping bool
+	// writers tracks the Write goroutines, so shutdown can wait for the queued frames to be written
+	writers sync.WaitGroup
+	// done is closed on shutdown, which stops Start
+	done     chan struct{}
+	shutdown sync.Once
+	// running is set while Start hands deliveries over to the clients
+	running int32
 }
 
 // Client is a websocket client
 type Client struct {
-	ID     string
-	Socket *websocket.Conn
-	Send   chan []byte
-	UUID   uuid.UUID
+	ID        string
+	Socket    *websocket.Conn
+	Send      chan []byte
+	UUID      uuid.UUID
+	ExpiresAt time.Time
+	Version   int
+	// SessionID is the session the access token used to open the socket was issued to, if any
+	SessionID string
+	// Logger tags the entries about the socket with its user and the ID of the request that opened it
+	Logger *zap.Logger
+
+	// Evicted is closed once the client is evicted, which makes Write close the socket
+	Evicted chan struct{}
+
+	// mu guards Send against being written to once closed or evicted
+	mu      sync.Mutex
+	closed  bool
+	evicted bool
 }
 
 // Manager define a ws server manager
 var Manager = ClientManager{
-	Broadcast:  make(chan []byte),
-	Register:   make(chan *Client),
-	Unregister: make(chan *Client),
-	Clients:    make(map[string][]*Client),
-}
-
-func updateMessage(message models.Message) (success bool, err error) {
-	var friendship models.Friendship
-	senderID, _ := primitive.ObjectIDFromHex(message.Sender)
-	recipientID, _ := primitive.ObjectIDFromHex(message.RecipientID)
-
-	filter := bson.D{
-		{"$or",
-			bson.A{
-				bson.M{
-					"$and": []interface{}{
-						bson.M{"requester._id": senderID},
-						bson.M{"recipient._id": recipientID},
-					},
-				},
-				bson.M{
-					"$and": []interface{}{
-						bson.M{"requester._id": recipientID},
-						bson.M{"recipient._id": senderID},
-					},
-				},
-			},
-		},
-	}
-	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	friendshipCollection.FindOne(ctx, filter).Decode(&friendship)
-	if message.UpdateType == "delivered" {
-		undelivered := false
-		for key, messages := range friendship.Recipient.Messages {
-			if !messages.Delivered && messages.RecipientID == message.Sender {
-				undelivered = true
-				friendship.Recipient.Messages[key].Delivered = true
-				friendship.Requester.Messages[key].Delivered = true
-			}
+	clients: make(map[string][]*Client),
+	done:    make(chan struct{}),
+}
+
+// enqueue queues a frame for the client without blocking.
+// The client is evicted if its queue is full, as it cannot keep up with its frames.
+func (c *Client) enqueue(frame []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || c.evicted {
+		return
+	}
+
+	select {
+	case c.Send <- frame:
+	default:
+		c.evict()
+	}
+}
+
+// evict disconnects a slow consumer, which ends Read and thus unregisters the client.
+// The client is only evicted once, as frames keep coming until it is unregistered.
+// It must be called with mu held, and leaves closing the socket to Write, so delivery never waits on the socket.
+func (c *Client) evict() {
+	c.evicted = true
+	c.Logger.Warn("evicting slow consumer")
+	metrics.WsEvictions.WithLabelValues(evictionSlowConsumer).Inc()
+	close(c.Evicted)
+}
+
+// close closes the queue of the client, which ends Write once the queued frames are written.
+func (c *Client) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		close(c.Send)
+	}
+}
+
+// conversationOf resolves the conversation addressed by a user and the users it should be delivered to.
+// Direct conversations need both users to be friends, while group conversations need the user to be a member.
+func conversationOf(app internal.Application, userId string, conversation models.Conversation) (conversationId string, audience []string, err error) {
+	if conversation.GroupID != "" {
+		group, err := app.Repositories.Groups.GetById(conversation.GroupID)
+		if err != nil {
+			return "", nil, err
 		}
-		if undelivered {
-			data, err := helper.ToDoc(friendship)
-			if err != nil {
-				return false, err
-			}
-			_, err = friendshipCollection.UpdateOne(ctx, filter, bson.D{
-				{"$set", data},
-			})
-			if err != nil {
-				return false, err
-			}
+		if _, isMember := group.Member(userId); !isMember {
+			return "", nil, repository.ErrRecordNotFound
 		}
-		return true, err
-	} else if message.UpdateType == "read" {
-		unread := false
-		for key, messages := range friendship.Recipient.Messages {
-			if !messages.Read && messages.RecipientID == message.Sender {
-				unread = true
-				friendship.Recipient.Messages[key].Delivered = true
-				friendship.Requester.Messages[key].Delivered = true
-				friendship.Recipient.Messages[key].Read = true
-				friendship.Requester.Messages[key].Read = true
-			}
+
+		for _, member := range group.Members {
+			audience = append(audience, member.UserID)
 		}
-		if unread {
-			data, err := helper.ToDoc(friendship)
-			if err != nil {
-				return false, err
-			}
-			_, err = friendshipCollection.UpdateOne(ctx, filter, bson.D{
-				{"$set", data},
-			})
-			if err != nil {
-				return false, err
+		return group.ID.Hex(), audience, nil
+	}
+
+	friendship, err := app.Repositories.Friendships.GetByPair(userId, conversation.RecipientID)
+	if err != nil {
+		return "", nil, err
+	}
+	if !friendship.Accepted {
+		return "", nil, repository.ErrRecordNotFound
+	}
+
+	return friendship.ID.Hex(), []string{userId, conversation.RecipientID}, nil
+}
+
+// updateReceipt moves the receipt cursor of the user forward to the message the update points at,
+// which has to be a message of the conversation sent by someone else.
+// Updates without a message ID apply to the latest message of the conversation.
+func updateReceipt(app internal.Application, conversationId string, userId string, update models.ReceiptUpdatePayload) (models.ReceiptPayload, error) {
+	messageId := update.MessageID
+	if messageId != "" {
+		// an unchecked ID could move the cursors past every message yet to be sent
+		message, err := app.Repositories.Messages.GetById(conversationId, messageId)
+		if err != nil {
+			return models.ReceiptPayload{}, err
+		}
+		if message.Sender == userId {
+			return models.ReceiptPayload{}, repository.ErrRecordNotFound
+		}
+	} else {
+		latest, err := app.Repositories.Messages.GetByConversation(conversationId, "", 1)
+		if err != nil {
+			return models.ReceiptPayload{}, err
+		}
+		if len(latest) == 0 {
+			return models.ReceiptPayload{}, repository.ErrRecordNotFound
+		}
+		messageId = latest[0].ID.Hex()
+	}
+
+	var err error
+	switch update.Status {
+	case "delivered":
+		err = app.Repositories.Receipts.MarkDelivered(conversationId, userId, messageId)
+	case "read":
+		err = app.Repositories.Receipts.MarkRead(conversationId, userId, messageId)
+	}
+	if err != nil {
+		return models.ReceiptPayload{}, err
+	}
+
+	return models.ReceiptPayload{
+		ConversationID: conversationId,
+		UserID:         userId,
+		MessageID:      messageId,
+		Status:         update.Status,
+	}, nil
+}
+
+// saveMessage stores a message unless the sender already sent one with the same client ID.
+// The stored message is returned either way, along with whether it was stored by this call.
+func saveMessage(app internal.Application, message models.Message) (models.Message, bool, error) {
+	existing, err := app.Repositories.Messages.GetByClientId(message.Sender, message.ClientID)
+	switch {
+	case err == nil:
+		return existing, false, nil
+
+	case !errors.Is(err, repository.ErrRecordNotFound):
+		return models.Message{}, false, err
+	}
+
+	created, err := app.Repositories.Messages.Create(message)
+	switch {
+	case err == nil:
+		return created, true, nil
+
+	case errors.Is(err, repository.ErrDuplicateRecord):
+		// lost a race against the same message resent on another socket
+		existing, err = app.Repositories.Messages.GetByClientId(message.Sender, message.ClientID)
+		return existing, false, err
+
+	default:
+		return models.Message{}, false, err
+	}
+}
+
+// send publishes the message to every socket of the given users, whichever node they are connected to.
+func (manager *ClientManager) send(app internal.Application, userIds []string, message []byte) {
+	for _, id := range userIds {
+		if err := app.Broker.Publish(id, message); err != nil {
+			app.Logger.Error("publishing frame", zap.String("user_id", id), zap.Error(err))
+		}
+	}
+}
+
+// deliver hands a message published to a user over to their sockets connected to this node.
+func (manager *ClientManager) deliver(userId string, message []byte) {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	for _, conn := range manager.clients[userId] {
+		conn.enqueue(message)
+	}
+}
+
+// disconnect tells every socket of the user that a session was revoked, which closes the sockets opened from it.
+// Every socket of the user is closed if no session ID is given.
+func (manager *ClientManager) disconnect(app internal.Application, userId string, sessionId string) {
+	manager.send(app, []string{userId}, encodeFrame(models.EventSessionRevoked, sessionId, nil))
+}
+
+// revokedBy reports whether the frame revokes the session of the client.
+func (c *Client) revokedBy(frame []byte) bool {
+	// most frames are told apart without unmarshalling them
+	if !bytes.HasPrefix(frame, []byte(`{"type":"`+models.EventSessionRevoked+`"`)) {
+		return false
+	}
+
+	envelope := models.Envelope{}
+	if err := json.Unmarshal(frame, &envelope); err != nil {
+		return false
+	}
+
+	return envelope.ID == "" || envelope.ID == c.SessionID
+}
+
+// register adds a client to the sockets of its user.
+// The first socket of a user on this node subscribes it to the frames published to them,
+// and their first socket on any node brings them online.
+func (manager *ClientManager) register(app internal.Application, conn *Client) {
+	conn.Logger.Info("socket connected")
+
+	lastSeq, err := app.Repositories.Events.LastSeq(conn.ID)
+	if err != nil {
+		conn.Logger.Error("reading last sequence number", zap.Error(err))
+	}
+	conn.enqueue(encodeFrame(models.EventWelcome, "", models.WelcomePayload{
+		UserID:  conn.ID,
+		Version: conn.Version,
+		LastSeq: lastSeq,
+	}))
+
+	// subscriptions change in the same order as the sockets of the user, without holding up delivery meanwhile
+	subscriber := &manager.subscribers[stripe(conn.ID, len(manager.subscribers))]
+	subscriber.Lock()
+	manager.mu.Lock()
+	manager.clients[conn.ID] = append(manager.clients[conn.ID], conn)
+	metrics.WsConnections.Inc()
+	first := len(manager.clients[conn.ID]) == 1
+	if first {
+		metrics.WsUsers.Inc()
+	}
+	manager.mu.Unlock()
+	if first {
+		if err := app.Broker.Subscribe(conn.ID); err != nil {
+			conn.Logger.Error("subscribing", zap.Error(err))
+		}
+	}
+	subscriber.Unlock()
+
+	presence, first, err := app.Presence.Connect(conn.ID)
+	if err != nil {
+		conn.Logger.Error("connecting presence", zap.Error(err))
+		return
+	}
+	if first {
+		manager.broadcastPresence(app, presence)
+	}
+}
+
+// unregister removes a client from the sockets of its user and closes its queue.
+// The last socket of a user on this node unsubscribes it from the frames published to them,
+// and their last socket on any node takes them offline.
+func (manager *ClientManager) unregister(app internal.Application, conn *Client) {
+	conn.Logger.Info("socket disconnected")
+
+	subscriber := &manager.subscribers[stripe(conn.ID, len(manager.subscribers))]
+	subscriber.Lock()
+	manager.mu.Lock()
+	found, last := false, false
+	for index, c := range manager.clients[conn.ID] {
+		if c.UUID == conn.UUID {
+			found = true
+			manager.clients[conn.ID] = remove(manager.clients[conn.ID], index)
+			metrics.WsConnections.Dec()
+			if len(manager.clients[conn.ID]) == 0 {
+				delete(manager.clients, conn.ID)
+				metrics.WsUsers.Dec()
+				last = true
 			}
+			break
+		}
+	}
+	manager.mu.Unlock()
+	if last {
+		if err := app.Broker.Unsubscribe(conn.ID); err != nil {
+			conn.Logger.Error("unsubscribing", zap.Error(err))
 		}
+	}
+	subscriber.Unlock()
 
+	conn.close()
+	if !found {
+		return
 	}
-	defer cancel()
-	return true, err
-}
-
-func saveMessage(MessageStruct models.Message) {
-	var friendship models.Friendship
-	senderID, _ := primitive.ObjectIDFromHex(MessageStruct.Sender)
-	recipientID, _ := primitive.ObjectIDFromHex(MessageStruct.RecipientID)
-
-	filter := bson.D{
-		{"$or",
-			bson.A{
-				bson.M{
-					"$and": []interface{}{
-						bson.M{"requester._id": senderID},
-						bson.M{"recipient._id": recipientID},
-					},
-				},
-				bson.M{
-					"$and": []interface{}{
-						bson.M{"requester._id": recipientID},
-						bson.M{"recipient._id": senderID},
-					},
-				},
-			},
-		},
-	}
-	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	friendshipCollection.FindOne(ctx, filter).Decode(&friendship)
-	friendship.Requester.Messages = append(friendship.Requester.Messages, MessageStruct)
-	friendship.Recipient.Messages = append(friendship.Recipient.Messages, MessageStruct)
-	data, err := helper.ToDoc(friendship)
-	_, err = friendshipCollection.UpdateOne(ctx, filter, bson.D{
-		{"$set", data},
-	})
+
+	presence, last, err := app.Presence.Disconnect(conn.ID)
+	if err != nil {
+		conn.Logger.Error("disconnecting presence", zap.Error(err))
+		return
+	}
+	if last {
+		manager.broadcastPresence(app, presence)
+	}
+}
+
+// maxResumeEvents is the most frames replayed to a resuming client before asking it to resync instead.
+// It leaves room in the queue of the client for the frames published meanwhile.
+const maxResumeEvents = sendQueueSize / 2
+
+// stripe spreads users over the given number of locks.
+func stripe(userId string, stripes int) uint32 {
+	hash := fnv.New32a()
+	hash.Write([]byte(userId))
+	return hash.Sum32() % uint32(stripes)
+}
+
+// publish stores a frame as an event of every given user, so it can be replayed to them, then delivers it.
+// Storing and publishing are serialized per user, so the events of a user published by this node reach them in order.
+// Users whose event cannot be stored still get the frame live, only without a sequence number.
+func (manager *ClientManager) publish(app internal.Application, userIds []string, eventType string, id string, payload interface{}) {
+	encodedPayload, _ := json.Marshal(payload)
+	createdAt := time.Now().UTC().Truncate(time.Second)
+
+	for _, userId := range userIds {
+		e := models.Event{
+			UserID:    userId,
+			Type:      eventType,
+			FrameID:   id,
+			Payload:   encodedPayload,
+			CreatedAt: createdAt,
+		}
+
+		sequencer := &manager.sequencers[stripe(userId, len(manager.sequencers))]
+		sequencer.Lock()
+		if stored, err := app.Repositories.Events.Append(e); err != nil {
+			app.Logger.Error("storing event", zap.String("user_id", userId), zap.String("type", eventType), zap.Error(err))
+		} else {
+			e = stored
+		}
+		manager.send(app, []string{userId}, encodeEvent(e))
+		sequencer.Unlock()
+	}
+}
+
+// resume replays to the client the events of its user following lastSeq.
+// The client is asked to resync if the events are too many or no longer stored.
+func (manager *ClientManager) resume(app internal.Application, c *Client, lastSeq int64) error {
+	latest, err := app.Repositories.Events.LastSeq(c.ID)
+	if err != nil {
+		return err
+	}
+
+	resync := encodeFrame(models.EventResync, "", models.ResumePayload{LastSeq: latest})
+	missed := latest - lastSeq
+	if missed < 0 || missed > maxResumeEvents {
+		c.enqueue(resync)
+		return nil
+	}
+
+	// nothing was missed, and a limit of 0 would retrieve every event
+	if missed == 0 {
+		c.enqueue(encodeFrame(models.EventResumed, "", models.ResumePayload{LastSeq: latest}))
+		return nil
+	}
+
+	events, err := app.Repositories.Events.GetAfter(c.ID, lastSeq, missed)
 	if err != nil {
-		fmt.Println(err)
+		return err
+	}
+
+	// expired events leave a gap at the start
+	if int64(len(events)) != missed || events[0].Seq != lastSeq+1 {
+		c.enqueue(resync)
+		return nil
+	}
+
+	for _, e := range events {
+		c.enqueue(encodeEvent(e))
 	}
-	defer cancel()
+	c.enqueue(encodeFrame(models.EventResumed, "", models.ResumePayload{LastSeq: latest}))
+
+	return nil
+}
+
+// except returns the user IDs without the given one.
+func except(userIds []string, userId string) []string {
+	others := []string{}
+	for _, id := range userIds {
+		if id != userId {
+			others = append(others, id)
+		}
+	}
+	return others
 }
 
 func remove(s []*Client, i int) []*Client {
@@ -159,134 +450,447 @@ func remove(s []*Client, i int) []*Client {
 	return s[:len(s)-1]
 }
 
+// conversationError maps the error of resolving a conversation to the one reported to the client.
+func conversationError(err error) error {
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return protocolError{models.ErrorConversationNotFound, "conversation not found"}
+	}
+	return err
+}
+
+// handle processes an event sent by a client, reporting failures back to the socket that sent it.
+func (manager *ClientManager) handle(app internal.Application, e event) {
+	// events are always sent on behalf of the authenticated user
+	sender := e.Client.ID
+
+	var err error
+	switch payload := e.Payload.(type) {
+	case *models.ResumePayload:
+		err = manager.resume(app, e.Client, payload.LastSeq)
+
+	case *models.PresenceUpdatePayload:
+		// presence updates concern friends rather than a conversation
+		var presence models.Presence
+		if presence, err = app.Presence.SetStatus(sender, payload.Status); err != nil {
+			break
+		}
+		manager.broadcastPresence(app, presence)
+
+	case *models.TypingPayload:
+		var conversationId string
+		var audience []string
+		if conversationId, audience, err = conversationOf(app, sender, payload.Conversation); err != nil {
+			err = conversationError(err)
+			break
+		}
+
+		// typing indicators are only relayed to the other members of the conversation
+		payload.ConversationID = conversationId
+		payload.UserID = sender
+		manager.send(app, except(audience, sender), encodeFrame(e.Type, "", payload))
+
+	case *models.ReceiptUpdatePayload:
+		var conversationId string
+		var audience []string
+		if conversationId, audience, err = conversationOf(app, sender, payload.Conversation); err != nil {
+			err = conversationError(err)
+			break
+		}
+
+		var receipt models.ReceiptPayload
+		if receipt, err = updateReceipt(app, conversationId, sender, *payload); err != nil {
+			if errors.Is(err, repository.ErrRecordNotFound) {
+				err = protocolError{models.ErrorMessageNotFound, "message not found"}
+			}
+			break
+		}
+
+		// every device of every member learns how far the user has got
+		manager.publish(app, audience, models.EventReceipt, "", receipt)
+
+	case *models.SendMessagePayload:
+		metrics.MessagesSent.Inc()
+
+		var conversationId string
+		var audience []string
+		if conversationId, audience, err = conversationOf(app, sender, payload.Conversation); err != nil {
+			err = conversationError(err)
+			break
+		}
+
+		message := models.Message{
+			ID:             primitive.NewObjectID(),
+			ConversationID: conversationId,
+			Sender:         sender,
+			ClientID:       e.ID,
+			RecipientID:    payload.RecipientID,
+			GroupID:        payload.GroupID,
+			Content:        payload.Content,
+		}
+		message.CreatedAt = time.Now().UTC().Truncate(time.Second)
+
+		// messages are only fanned out once stored, and resent ones only acknowledged again
+		var created bool
+		if message, created, err = saveMessage(app, message); err != nil {
+			e.Client.Logger.Error("storing message", zap.String("frame_id", e.ID), zap.Error(err))
+			break
+		}
+		if created {
+			metrics.MessagesPersisted.Inc()
+			manager.publish(app, audience, models.EventMessageNew, message.ID.Hex(), message)
+		}
+
+		e.Client.enqueue(encodeFrame(models.EventAck, e.ID, models.AckPayload{
+			MessageID:      message.ID.Hex(),
+			ConversationID: message.ConversationID,
+			CreatedAt:      message.CreatedAt,
+		}))
+	}
+
+	if err != nil {
+		e.Client.enqueue(encodeError(e.ID, e.Type, err))
+	}
+}
+
 //Start is before the project runs, the program starts start > go Manager.Start ()
-func (manager *ClientManager) Start() {
+// It returns once the broker closes or the manager shuts down.
+func (manager *ClientManager) Start(app internal.Application) {
+	atomic.StoreInt32(&manager.running, 1)
+	defer atomic.StoreInt32(&manager.running, 0)
+
+	deliveries := app.Broker.Deliveries()
+
+	// enqueueing never blocks, so a slow socket cannot hold the others up
 	for {
-		log.Println("< --- pipeline communication -- >")
 		select {
-		case conn := <-Manager.Register:
-			log.Printf(("new user joined in% v"), conn.ID)
-			Manager.Clients[conn.ID] = append(Manager.Clients[conn.ID], conn)
-			jsonMessage, _ := json.Marshal(&models.Message{Content: "Successful connection to socket service"})
-			conn.Send <- jsonMessage
-		case conn := <-Manager.Unregister:
-			log.Printf(("user left% v"), conn.ID)
-			if _, ok := Manager.Clients[conn.ID]; ok {
-				// jsonMessage, _ := json.Marshal(&models.Message{Content: "A socket has disconnected"})
-				// conn.Send <- jsonMessage
-				// if len(Manager.Clients[conn.ID]) <= 1 {
-				// 	close(conn.Send)
-				// }
-				for index, c := range manager.Clients[conn.ID] {
-					if c.UUID == conn.UUID {
-						manager.Clients[conn.ID] = remove(manager.Clients[conn.ID], index)
-					}
-				}
-			}
-		case message := <-Manager.Broadcast:
-			MessageStruct := models.Message{}
-			json.Unmarshal(message, &MessageStruct)
-
-			fmt.Println(MessageStruct)
-			if MessageStruct.MessageType == "info" {
-				success, _ := updateMessage(MessageStruct)
-				if success {
-					for id, conns := range Manager.Clients {
-						if id == MessageStruct.Sender || id == MessageStruct.RecipientID {
-							for _, conn := range conns {
-								conn.Send <- message
-							}
-						}
-					}
-				}
-			} else {
-				for id, conns := range Manager.Clients {
-					if id == MessageStruct.Sender || id == MessageStruct.RecipientID {
-						for _, conn := range conns {
-							conn.Send <- message
-						}
-					}
-				}
-				saveMessage(MessageStruct)
+		case delivery, ok := <-deliveries:
+			if !ok {
+				return
 			}
+			manager.deliver(delivery.UserID, delivery.Frame)
+
+		case <-manager.done:
+			return
 		}
 	}
 }
-func (c *Client) Read() {
+
+// Running reports whether Start is handing deliveries over to the clients.
+func (manager *ClientManager) Running() bool {
+	return atomic.LoadInt32(&manager.running) == 1
+}
+
+// startHandling reports whether a frame may still be handled, tracking it until doneHandling if so.
+func (manager *ClientManager) startHandling() bool {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	if manager.stopping {
+		return false
+	}
+	manager.handlers.Add(1)
+	return true
+}
+
+// doneHandling marks a frame tracked by startHandling as handled.
+func (manager *ClientManager) doneHandling() {
+	manager.handlers.Done()
+}
+
+// Shutdown stops Start and handling frames, waits for the frames being handled,
+// then closes the queue of every client, which makes Write flush the frames queued and close the socket as going away.
+// It returns once every queue is flushed or the context is done.
+func (manager *ClientManager) Shutdown(ctx context.Context) error {
+	manager.shutdown.Do(func() { close(manager.done) })
+
+	manager.mu.Lock()
+	manager.stopping = true
+	manager.mu.Unlock()
+
+	if err := wait(ctx, &manager.handlers); err != nil {
+		return err
+	}
+
+	manager.mu.RLock()
+	var clients []*Client
+	for _, conns := range manager.clients {
+		clients = append(clients, conns...)
+	}
+	manager.mu.RUnlock()
+
+	for _, c := range clients {
+		c.close()
+	}
+
+	return wait(ctx, &manager.writers)
+}
+
+// wait waits for the wait group, returning early with the error of the context if it is done first.
+func wait(ctx context.Context, group *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		group.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Read handles the frames sent by the client, one at a time, until the socket closes.
+// Clients that stop answering pings for longer than the pong wait are disconnected.
+func (c *Client) Read(app internal.Application) {
 	defer func() {
-		Manager.Unregister <- c
+		Manager.unregister(app, c)
 		c.Socket.Close()
 	}()
 
+	// frames over the limit close the socket with CloseMessageTooBig
+	c.Socket.SetReadLimit(app.Config.Ws.MaxMessageSize)
+	c.Socket.SetReadDeadline(time.Now().Add(app.Config.Ws.PongWait))
+	c.Socket.SetPongHandler(func(string) error {
+		return c.Socket.SetReadDeadline(time.Now().Add(app.Config.Ws.PongWait))
+	})
+
 	for {
-		c.Socket.PongHandler()
 		_, message, err := c.Socket.ReadMessage()
 		if err != nil {
-			Manager.Unregister <- c
-			c.Socket.Close()
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				c.Logger.Warn("evicting unresponsive client")
+				metrics.WsEvictions.WithLabelValues(evictionPongTimeout).Inc()
+			}
 			break
 		}
-		log.Printf("message read to client: s", string(message))
-		Manager.Broadcast <- message
+
+		// frames are logged without their payload, which may hold message contents
+		e, err := decodeEvent(c, message)
+		if err != nil {
+			c.Logger.Debug("invalid frame", zap.String("type", e.Type), zap.String("frame_id", e.ID), zap.Error(err))
+			c.enqueue(encodeError(e.ID, e.Type, err))
+			continue
+		}
+		c.Logger.Debug("frame received", zap.String("type", e.Type), zap.String("frame_id", e.ID), zap.Int("bytes", len(message)))
+
+		// frames received once shutting down are dropped, as the repositories are about to close
+		if !Manager.startHandling() {
+			continue
+		}
+		Manager.handle(app, e)
+		Manager.doneHandling()
 	}
 }
 
-func (c *Client) Write() {
+// Write writes the queued frames to the socket and pings it, until the queue closes or a write fails.
+func (c *Client) Write(app internal.Application) {
+	ping := time.NewTicker(app.Config.Ws.PingInterval)
 	defer func() {
+		ping.Stop()
 		c.Socket.Close()
+		Manager.writers.Done()
 	}()
 
+	// close the socket once the access token used to open it expires
+	expiry := time.NewTimer(time.Until(c.ExpiresAt))
+	defer expiry.Stop()
+
 	for {
 		select {
 		case message, ok := <-c.Send:
+			c.Socket.SetWriteDeadline(time.Now().Add(app.Config.Ws.WriteWait))
+			// the queue only closes once the socket is unregistered or the server shuts down
 			if !ok {
-				c.Socket.WriteMessage(websocket.CloseMessage, []byte{})
+				c.Socket.WriteMessage(
+					websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+				)
 				return
 			}
-			log.Printf("message sent to client: s", string(message))
-			c.Socket.WriteMessage(websocket.TextMessage, message)
-		}
-
-	}
-}
-
-// func (c *Client) PingHandler() {
-// 	defer func() {
-// 		c.Socket.Close()
-// 	}()
-// 	MessageStruct := models.Message{
-// 		MessageType: "info",
-// 		Content:     "Ping",
-// 	}
-// 	message, err := json.Marshal(MessageStruct)
-// 	if err != nil {
-// 		Manager.Unregister <- c
-// 		c.Socket.Close()
-// 	}
-// 	for _ = range time.Tick(5 * time.Second) {
-// 		log.Printf("message sent to client: s", string(message))
-// 		c.Socket.WriteMessage(websocket.TextMessage, message)
-// 	}
-// }
-
-//WsHandler socket connection middleware function: upgrade protocol, user authentication, user-defined information, etc
-func WsHandler(c *gin.Context) {
-	conn, err := (&websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}).Upgrade(c.Writer, c.Request, nil)
+			c.Logger.Debug("frame sent", zap.Int("bytes", len(message)))
+			// a failed write means the socket is gone, closing it ends Read as well
+			if err := c.Socket.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+			if c.revokedBy(message) {
+				c.Socket.WriteControl(
+					websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session revoked"),
+					time.Now().Add(app.Config.Ws.WriteWait),
+				)
+				// closing the socket ends Read, which unregisters the client and closes Send
+				c.Socket.Close()
+			}
+
+		case <-c.Evicted:
+			c.Socket.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "slow consumer"),
+				time.Now().Add(app.Config.Ws.WriteWait),
+			)
+			// closing the socket ends Read, which unregisters the client and closes Send
+			return
+
+		case <-ping.C:
+			if err := c.Socket.WriteControl(websocket.PingMessage, nil, time.Now().Add(app.Config.Ws.WriteWait)); err != nil {
+				return
+			}
+
+		case <-expiry.C:
+			c.Socket.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "token expired"),
+				time.Now().Add(app.Config.Ws.WriteWait),
+			)
+			// closing the socket ends Read, which unregisters the client and closes Send
+			c.Socket.Close()
+		}
+	}
+}
+
+// socketTokenProtocol is the WebSocket subprotocol under which browsers pass the access token,
+// i.e. new WebSocket(url, ["access_token", token]).
+const socketTokenProtocol = "access_token"
+
+// authTimeout is how long an unauthenticated socket has to send its token as the first frame.
+const authTimeout = 10 * time.Second
+
+// socketUpgrader upgrades HTTP connections to WebSocket connections.
+var socketUpgrader = websocket.Upgrader{
+	CheckOrigin:  func(r *http.Request) bool { return true },
+	Subprotocols: []string{versionProtocol(models.ProtocolVersion), socketTokenProtocol},
+}
+
+// requestSocketToken extracts the access token from the Sec-WebSocket-Protocol header or the "token" query parameter.
+// An empty string is returned if neither is provided.
+func requestSocketToken(ctx *gin.Context) string {
+	protocols := websocket.Subprotocols(ctx.Request)
+	for index, protocol := range protocols {
+		if protocol == socketTokenProtocol && index+1 < len(protocols) {
+			return protocols[index+1]
+		}
+	}
+
+	return ctx.Query("token")
+}
+
+// frameSocketToken reads the access token from the first frame of the socket, which must be an auth frame.
+func frameSocketToken(conn *websocket.Conn, version int) (string, error) {
+	conn.SetReadDeadline(time.Now().Add(authTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	_, message, err := conn.ReadMessage()
 	if err != nil {
-		http.NotFound(c.Writer, c.Request)
-		return
+		return "", err
+	}
+
+	envelope, err := decodeEnvelope(message, version)
+	if err != nil {
+		return "", err
+	}
+	if envelope.Type != models.EventAuth {
+		return "", protocolError{models.ErrorUnknownType, "expected an auth frame"}
+	}
+
+	payload := models.AuthPayload{}
+	if err = decodePayload(envelope, &payload); err != nil {
+		return "", err
+	}
+
+	return payload.Token, nil
+}
+
+// authenticateSocket validates the access token and retrieves the claims of the user it belongs to.
+func authenticateSocket(app internal.Application, token string) (*helper.Claims, error) {
+	claims, err := helper.ValidateToken(app.Config.JwtSecret, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = app.Repositories.Users.GetById(claims.Subject); err != nil {
+		return nil, err
+	}
+
+	// the session may have been revoked since the token was issued
+	if claims.SessionID != "" {
+		session, err := app.Repositories.Sessions.GetById(claims.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		if session.UserID != claims.Subject {
+			return nil, repository.ErrRecordNotFound
+		}
+	}
+
+	return claims, nil
+}
+
+// WsHandler socket connection middleware function: upgrade protocol, user authentication, user-defined information, etc
+// The access token is accepted via the Sec-WebSocket-Protocol header, the "token" query parameter or the first frame.
+func WsHandler(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		version, ok := negotiateVersion(ctx)
+		if !ok {
+			helper.AbortWithError(ctx, http.StatusBadRequest, helper.ErrorInvalidRequest, "unsupported protocol version")
+			return
+		}
+
+		// reject invalid tokens provided with the request before upgrading
+		var claims *helper.Claims
+		token := requestSocketToken(ctx)
+		if token != "" {
+			var err error
+			if claims, err = authenticateSocket(app, token); err != nil {
+				helper.AbortWithError(ctx, http.StatusUnauthorized, helper.ErrorUnauthorized, "invalid or expired token")
+				return
+			}
+		}
+
+		conn, err := socketUpgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+		if err != nil {
+			return
+		}
+
+		// otherwise expect the token as the first frame
+		if claims == nil {
+			token, err = frameSocketToken(conn, version)
+			if err == nil {
+				claims, err = authenticateSocket(app, token)
+			}
+			if err != nil {
+				conn.WriteControl(
+					websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "invalid or expired token"),
+					time.Now().Add(time.Second),
+				)
+				conn.Close()
+				return
+			}
+		}
+
+		id, _ := uuid.New()
+		client := &Client{
+			ID:        claims.Subject,
+			Socket:    conn,
+			Send:      make(chan []byte, sendQueueSize),
+			Evicted:   make(chan struct{}),
+			UUID:      id,
+			ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+			Version:   version,
+			SessionID: claims.SessionID,
+			Logger: helper.Logger(ctx).With(
+				zap.String("user_id", claims.Subject),
+				zap.String("socket_id", hex.EncodeToString(id[:])),
+			),
+		}
+		Manager.register(app, client)
+		Manager.writers.Add(1)
+		go client.Read(app)
+		go client.Write(app)
 	}
-	//User information authentication can be added
-	id, _ := uuid.New()
-	client := &Client{
-		ID:     c.Query("uid"),
-		Socket: conn,
-		Send:   make(chan []byte),
-		UUID:   id,
-	}
-	Manager.Register <- client
-	go client.Read()
-	go client.Write()
 }
 
 func Pong() gin.HandlerFunc {
@@ -0,0 +1,324 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
+	"github.com/Mutay1/chat-backend/domain/repository"
+	helper "github.com/Mutay1/chat-backend/helpers"
+	"github.com/Mutay1/chat-backend/infrastructure/memory"
+	"github.com/Mutay1/chat-backend/infrastructure/metrics"
+	"github.com/Mutay1/chat-backend/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/uuid"
+	"go.uber.org/zap"
+)
+
+// benchmarkHub starts a hub over an in-process broker with the given number of connected clients,
+// of which every slowEvery-th never reads its queue, if slowEvery is positive.
+// It returns the application and hub, the IDs of the users and the count of frames received by the clients reading their queue.
+func benchmarkHub(b *testing.B, clients int, slowEvery int) (internal.Application, *ClientManager, []string, *int64) {
+	b.Helper()
+
+	app := internal.Application{
+		Repositories: memory.NewRepositories(),
+		Broker:       memory.NewBroker(),
+		Presence:     memory.NewPresence(),
+		Logger:       zap.NewNop(),
+	}
+	manager := &ClientManager{clients: make(map[string][]*Client)}
+	go manager.Start(app)
+	b.Cleanup(func() { app.Broker.Close() })
+
+	var received int64
+	userIds := make([]string, clients)
+	for i := range userIds {
+		id, _ := uuid.New()
+		client := &Client{
+			ID:      primitive.NewObjectID().Hex(),
+			Send:    make(chan []byte, sendQueueSize),
+			Evicted: make(chan struct{}),
+			UUID:    id,
+			Logger:  zap.NewNop(),
+		}
+		userIds[i] = client.ID
+		manager.register(app, client)
+
+		// drain the welcome frame
+		<-client.Send
+		if slowEvery > 0 && i%slowEvery == 0 {
+			continue
+		}
+		go func() {
+			for range client.Send {
+				atomic.AddInt64(&received, 1)
+			}
+		}()
+	}
+
+	return app, manager, userIds, &received
+}
+
+// waitFor yields until the clients reading their queue received the given count of frames.
+func waitFor(received *int64, frames int64) {
+	for atomic.LoadInt64(received) < frames {
+		runtime.Gosched()
+	}
+}
+
+// BenchmarkHubDelivery measures how many frames the hub delivers per second to clients spread over many users.
+func BenchmarkHubDelivery(b *testing.B) {
+	for _, clients := range []int{1000, 5000} {
+		b.Run(fmt.Sprintf("clients=%d", clients), func(b *testing.B) {
+			app, manager, userIds, received := benchmarkHub(b, clients, 0)
+			frame := encodeFrame("benchmark", "", map[string]string{"content": "hello"})
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				manager.send(app, []string{userIds[i%len(userIds)]}, frame)
+			}
+			waitFor(received, int64(b.N))
+			b.StopTimer()
+
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "frames/s")
+		})
+	}
+}
+
+// BenchmarkHubDeliveryWithSlowConsumers measures the same throughput with one client in a hundred never reading,
+// which must not hold the others up.
+func BenchmarkHubDeliveryWithSlowConsumers(b *testing.B) {
+	app, manager, userIds, received := benchmarkHub(b, 5000, 100)
+	frame := encodeFrame("benchmark", "", map[string]string{"content": "hello"})
+
+	// only count the frames of clients reading their queue
+	var fast []string
+	for i, userId := range userIds {
+		if i%100 != 0 {
+			fast = append(fast, userId)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		manager.send(app, []string{fast[i%len(fast)], userIds[(i%50)*100]}, frame)
+	}
+	waitFor(received, int64(b.N))
+	b.StopTimer()
+
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "frames/s")
+}
+
+// slowEvents stalls after storing every event, widening the window for events to be published out of order.
+type slowEvents struct {
+	repository.EventRepository
+}
+
+func (e slowEvents) Append(event models.Event) (models.Event, error) {
+	event, err := e.EventRepository.Append(event)
+	time.Sleep(time.Duration(rand.Intn(1000)) * time.Microsecond)
+	return event, err
+}
+
+func TestPublishOrdersEvents(t *testing.T) {
+	app := internal.Application{
+		Repositories: memory.NewRepositories(),
+		Broker:       memory.NewBroker(),
+		Presence:     memory.NewPresence(),
+		Logger:       zap.NewNop(),
+	}
+	app.Repositories.Events = slowEvents{app.Repositories.Events}
+	manager := &ClientManager{clients: make(map[string][]*Client)}
+	go manager.Start(app)
+	defer app.Broker.Close()
+
+	id, _ := uuid.New()
+	client := &Client{
+		ID:      primitive.NewObjectID().Hex(),
+		Send:    make(chan []byte, sendQueueSize),
+		Evicted: make(chan struct{}),
+		UUID:    id,
+		Logger:  zap.NewNop(),
+	}
+	manager.register(app, client)
+	<-client.Send
+
+	// events published concurrently for the same user still reach them in sequence
+	const publishers, events = 8, 10
+	for i := 0; i < publishers; i++ {
+		go func() {
+			for j := 0; j < events; j++ {
+				manager.publish(app, []string{client.ID}, models.EventReceipt, "", models.ReceiptPayload{})
+			}
+		}()
+	}
+
+	for seq := int64(1); seq <= publishers*events; seq++ {
+		select {
+		case frame := <-client.Send:
+			envelope := models.Envelope{}
+			if err := json.Unmarshal(frame, &envelope); err != nil {
+				t.Fatal(err)
+			}
+			if envelope.Seq != seq {
+				t.Fatalf("expected event %d, got %d", seq, envelope.Seq)
+			}
+
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected event %d to be delivered", seq)
+		}
+	}
+}
+
+func TestClientEviction(t *testing.T) {
+	client := &Client{
+		Send:    make(chan []byte, 1),
+		Evicted: make(chan struct{}),
+		Logger:  zap.NewNop(),
+	}
+	evictions := metrics.WsEvictions.WithLabelValues(evictionSlowConsumer)
+	before := testutil.ToFloat64(evictions)
+
+	// frames keep coming until the evicted client is unregistered
+	for i := 0; i < 5; i++ {
+		client.enqueue([]byte("frame"))
+	}
+
+	if len(client.Send) != 1 {
+		t.Fatalf("expected the frames after the first to be dropped, got %d queued", len(client.Send))
+	}
+	if got := testutil.ToFloat64(evictions) - before; got != 1 {
+		t.Fatalf("expected a single eviction to be counted, got %v", got)
+	}
+	select {
+	case <-client.Evicted:
+	default:
+		t.Fatal("expected Write to be told to close the socket")
+	}
+
+	// the queue is still closed once the client is unregistered
+	client.close()
+	<-client.Send
+	if _, open := <-client.Send; open {
+		t.Fatal("expected the queue to be closed")
+	}
+}
+
+func TestManagerShutdownWaitsForHandlers(t *testing.T) {
+	manager := &ClientManager{
+		clients: make(map[string][]*Client),
+		done:    make(chan struct{}),
+	}
+	if !manager.startHandling() {
+		t.Fatal("expected frames to be handled before shutdown")
+	}
+
+	// the frame being handled holds shutdown up
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := manager.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected shutdown to wait for the handler, got %v", err)
+	}
+	if manager.startHandling() {
+		t.Fatal("expected no more frames to be handled once shutting down")
+	}
+
+	manager.doneHandling()
+	if err := manager.Shutdown(context.Background()); err != nil {
+		t.Fatalf("expected shutdown to complete once the frame is handled, got %v", err)
+	}
+}
+
+func TestManagerShutdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	app := internal.Application{
+		Repositories: memory.NewRepositories(),
+		Broker:       memory.NewBroker(),
+		Presence:     memory.NewPresence(),
+		Logger:       zap.NewNop(),
+	}
+	app.Config.JwtSecret = "test-secret"
+	app.Config.Ws.PingInterval = time.Minute
+	app.Config.Ws.PongWait = time.Minute
+	app.Config.Ws.WriteWait = time.Second
+	app.Config.Ws.MaxMessageSize = 4096
+	defer app.Broker.Close()
+
+	userId := primitive.NewObjectID()
+	user, err := app.Repositories.Users.Create(models.User{ID: userId, UserID: userId.Hex()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := helper.GenerateTokens(app.Config.JwtSecret, user.UserID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		close(started)
+		Manager.Start(app)
+		close(stopped)
+	}()
+	<-started
+
+	router := gin.New()
+	router.GET("/ws", WsHandler(app))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws?token="+token, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// frames queued before the shutdown are still written
+	Manager.mu.RLock()
+	client := Manager.clients[user.UserID][0]
+	Manager.mu.RUnlock()
+	client.enqueue(encodeFrame(models.EventPresence, "", models.Presence{UserID: "friend"}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Manager.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	var types []string
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		envelope := models.Envelope{}
+		if err = conn.ReadJSON(&envelope); err != nil {
+			break
+		}
+		types = append(types, envelope.Type)
+	}
+	if strings.Join(types, ",") != "welcome,presence" {
+		t.Fatalf("expected the queued frames to be flushed, got %v", types)
+	}
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("expected the socket to close as going away, got %v", err)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the hub to stop")
+	}
+}
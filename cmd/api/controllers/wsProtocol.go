@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Mutay1/chat-backend/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// versionProtocolPrefix prefixes the WebSocket subprotocols under which clients offer protocol versions,
+// i.e. new WebSocket(url, ["chat.v1"]).
+const versionProtocolPrefix = "chat.v"
+
+// supportedVersions lists the protocol versions the server can speak.
+var supportedVersions = map[int]bool{
+	models.ProtocolVersion: true,
+}
+
+// payloadTypes maps the frames clients may send to the payload they carry.
+var payloadTypes = map[string]func() interface{}{
+	models.EventMessageSend:    func() interface{} { return &models.SendMessagePayload{} },
+	models.EventReceiptUpdate:  func() interface{} { return &models.ReceiptUpdatePayload{} },
+	models.EventPresenceUpdate: func() interface{} { return &models.PresenceUpdatePayload{} },
+	models.EventTypingStart:    func() interface{} { return &models.TypingPayload{} },
+	models.EventTypingStop:     func() interface{} { return &models.TypingPayload{} },
+	models.EventResume:         func() interface{} { return &models.ResumePayload{} },
+}
+
+// event is a validated frame sent by a client, on its way to the hub.
+type event struct {
+	Client  *Client
+	ID      string
+	Type    string
+	Payload interface{}
+}
+
+// protocolError is reported to the client in an error frame.
+type protocolError struct {
+	Code    string
+	Message string
+}
+
+func (e protocolError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// versionProtocol returns the subprotocol offering the given protocol version.
+func versionProtocol(version int) string {
+	return versionProtocolPrefix + strconv.Itoa(version)
+}
+
+// negotiateVersion picks the highest supported protocol version offered through the subprotocols
+// or the "version" query parameter, or the latest version if the client offers none.
+// False is returned if none of the offered versions is supported.
+func negotiateVersion(ctx *gin.Context) (int, bool) {
+	offered := []string{}
+	for _, protocol := range websocket.Subprotocols(ctx.Request) {
+		if strings.HasPrefix(protocol, versionProtocolPrefix) {
+			offered = append(offered, strings.TrimPrefix(protocol, versionProtocolPrefix))
+		}
+	}
+	if versionQuery := ctx.Query("version"); versionQuery != "" {
+		offered = append(offered, versionQuery)
+	}
+	if len(offered) == 0 {
+		return models.ProtocolVersion, true
+	}
+
+	negotiated := 0
+	for _, offer := range offered {
+		version, err := strconv.Atoi(offer)
+		if err == nil && supportedVersions[version] && version > negotiated {
+			negotiated = version
+		}
+	}
+
+	return negotiated, negotiated != 0
+}
+
+// decodeStrict unmarshals JSON, rejecting fields unknown to v.
+func decodeStrict(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// decodeEnvelope unmarshals a frame and checks it is tagged with the negotiated protocol version.
+func decodeEnvelope(data []byte, version int) (models.Envelope, error) {
+	envelope := models.Envelope{}
+	if err := decodeStrict(data, &envelope); err != nil {
+		return envelope, protocolError{models.ErrorInvalidFrame, err.Error()}
+	}
+	if envelope.Version != version {
+		return envelope, protocolError{
+			models.ErrorUnsupportedVersion,
+			fmt.Sprintf("frames must be tagged with version %d", version),
+		}
+	}
+
+	return envelope, nil
+}
+
+// decodePayload unmarshals and validates the payload of a frame.
+func decodePayload(envelope models.Envelope, payload interface{}) error {
+	if len(envelope.Payload) == 0 {
+		return protocolError{models.ErrorInvalidPayload, "missing payload"}
+	}
+	if err := decodeStrict(envelope.Payload, payload); err != nil {
+		return protocolError{models.ErrorInvalidPayload, err.Error()}
+	}
+	if err := validate.Struct(payload); err != nil {
+		return protocolError{models.ErrorInvalidPayload, err.Error()}
+	}
+
+	return nil
+}
+
+// decodeEvent turns a frame sent by the client into an event for the hub.
+// The ID and type of the frame are returned alongside decoding errors so they can be reported against it.
+func decodeEvent(c *Client, data []byte) (event, error) {
+	envelope, err := decodeEnvelope(data, c.Version)
+	if err != nil {
+		return event{ID: envelope.ID, Type: envelope.Type}, err
+	}
+
+	newPayload, ok := payloadTypes[envelope.Type]
+	if !ok {
+		return event{ID: envelope.ID}, protocolError{
+			models.ErrorUnknownType,
+			fmt.Sprintf("unknown frame type %q", envelope.Type),
+		}
+	}
+
+	// messages are identified by the client so they can be resent safely
+	if envelope.Type == models.EventMessageSend && envelope.ID == "" {
+		return event{}, protocolError{models.ErrorInvalidFrame, "message.send frames need an id"}
+	}
+
+	payload := newPayload()
+	if err = decodePayload(envelope, payload); err != nil {
+		return event{ID: envelope.ID, Type: envelope.Type}, err
+	}
+
+	return event{Client: c, ID: envelope.ID, Type: envelope.Type, Payload: payload}, nil
+}
+
+// encodeFrame wraps a payload in an envelope of the latest protocol version.
+func encodeFrame(eventType string, id string, payload interface{}) []byte {
+	var encodedPayload json.RawMessage
+	if payload != nil {
+		encodedPayload, _ = json.Marshal(payload)
+	}
+	frame, _ := json.Marshal(&models.Envelope{
+		Type:    eventType,
+		ID:      id,
+		Version: models.ProtocolVersion,
+		Payload: encodedPayload,
+	})
+
+	return frame
+}
+
+// encodeEvent builds the frame of a stored event.
+func encodeEvent(e models.Event) []byte {
+	frame, _ := json.Marshal(&models.Envelope{
+		Type:    e.Type,
+		ID:      e.FrameID,
+		Version: models.ProtocolVersion,
+		Seq:     e.Seq,
+		Payload: e.Payload,
+	})
+
+	return frame
+}
+
+// encodeError builds the frame reporting err against the frame with the given ID and type.
+// Failed messages are reported with a nack, so clients know to resend them, and other frames with an error.
+func encodeError(id string, frameType string, err error) []byte {
+	var reported protocolError
+	if !errors.As(err, &reported) {
+		reported = protocolError{models.ErrorInternal, "internal error"}
+	}
+
+	if frameType == models.EventMessageSend && id != "" {
+		return encodeFrame(models.EventNack, id, models.NackPayload{
+			Code:   reported.Code,
+			Reason: reported.Message,
+		})
+	}
+
+	return encodeFrame(models.EventError, id, models.ErrorPayload{
+		Code:    reported.Code,
+		Message: reported.Message,
+	})
+}
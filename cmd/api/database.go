@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"github.com/Mutay1/chat-backend/cmd/api/internal"
+	"github.com/Mutay1/chat-backend/domain/repository"
+	"github.com/Mutay1/chat-backend/infrastructure/database"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"time"
@@ -38,3 +40,19 @@ func connectClient(config internal.Config) (*mongo.Client, error) {
 
 	return client, nil
 }
+
+// mongoRepositories returns the repositories backed by the given database
+func mongoRepositories(db *mongo.Database) repository.Repositories {
+	return repository.Repositories{
+		Users:       database.UserController{Db: db},
+		Friendships: database.FriendshipController{Db: db},
+		Messages:    database.MessageController{Db: db},
+		Groups:      database.GroupController{Db: db},
+		Receipts:    database.ReceiptController{Db: db},
+		Events:      database.EventController{Db: db},
+		Health:      database.HealthController{Db: db},
+		Tokens:      database.OneTimeTokenController{Db: db},
+		Audit:       database.AuditController{Db: db},
+		Sessions:    database.SessionController{Db: db},
+	}
+}
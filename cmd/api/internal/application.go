@@ -1,9 +1,19 @@
 package internal
 
-import "github.com/Mutay1/chat-backend/domain/repository"
+import (
+	"github.com/Mutay1/chat-backend/domain/broker"
+	"github.com/Mutay1/chat-backend/domain/mailer"
+	"github.com/Mutay1/chat-backend/domain/presence"
+	"github.com/Mutay1/chat-backend/domain/repository"
+	"go.uber.org/zap"
+)
 
 // Application is a container to group data needed at different points throughout the server.
 type Application struct {
 	Config       Config
 	Repositories repository.Repositories
+	Broker       broker.Broker
+	Presence     presence.Tracker
+	Logger       *zap.Logger
+	Mailer       mailer.Mailer
 }
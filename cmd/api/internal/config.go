@@ -5,22 +5,73 @@ import (
 	"flag"
 	"os"
 	"strconv"
+	"time"
+)
+
+// Supported database drivers.
+const (
+	DbDriverMongo  = "mongo"
+	DbDriverMemory = "memory"
+)
+
+// Supported brokers.
+const (
+	BrokerMemory = "memory"
+	BrokerRedis  = "redis"
+)
+
+// Supported mailers.
+const (
+	MailerFile = "file"
+	MailerSmtp = "smtp"
 )
 
 type Config struct {
 	Env            string
 	Version        string
+	Commit         string
+	BuildTime      string
 	Port           int
 	JwtSecret      string
 	DisplayVersion bool
+	Migrate        bool
+
+	// AppUrl is the address of the web client, which the links in emails point to
+	AppUrl string
+
+	// ShutdownTimeout is how long in-flight requests and queued frames have to complete on shutdown
+	ShutdownTimeout time.Duration
 
 	Db struct {
+		Driver       string
 		Name         string
 		Uri          string
 		MaxOpenConns int
 		MaxIdleConns int
 		MaxIdleTime  string
 	}
+
+	Broker struct {
+		Driver   string
+		RedisUrl string
+	}
+
+	Ws struct {
+		PingInterval   time.Duration
+		PongWait       time.Duration
+		WriteWait      time.Duration
+		MaxMessageSize int64
+	}
+
+	Mail struct {
+		Driver       string
+		From         string
+		Dir          string
+		SmtpHost     string
+		SmtpPort     int
+		SmtpUsername string
+		SmtpPassword string
+	}
 }
 
 func (c *Config) Parse() {
@@ -28,24 +79,102 @@ func (c *Config) Parse() {
 	flag.IntVar(&c.Port, "port", c.defaultPort(), "API server port\nDotenv variable: PORT\n")
 	flag.StringVar(&c.JwtSecret, "jwt-secret", c.defaultJwtSecret(), "JWT Secret Key\nDotEnv variable: JWT_SECRET\n")
 	flag.BoolVar(&c.DisplayVersion, "version", false, "Display version and build time")
+	flag.BoolVar(&c.Migrate, "migrate", false, "Run database migrations and exit")
+	flag.StringVar(&c.AppUrl, "app-url", c.defaultAppUrl(), "URL of the web client linked to in emails\nDotenv variable: APP_URL\n")
+	flag.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.defaultShutdownTimeout(), "Time allowed to drain connections on shutdown\nDotenv variable: SHUTDOWN_TIMEOUT\n")
 
+	flag.StringVar(&c.Db.Driver, "db-driver", c.defaultDbDriver(), "Database driver (mongo|memory)\nDotenv variable: DB_DRIVER\n")
 	flag.StringVar(&c.Db.Uri, "db-uri", c.defaultDbUri(), "MongoDB Connection String URI\nDotenv variable: DB_URI\n")
 	flag.StringVar(&c.Db.Name, "db-name", c.defaultDbName(), "MongoDB Database Name\nDotenv variable: DB_NAME\n")
 	flag.IntVar(&c.Db.MaxOpenConns, "db-max-open-conns", c.defaultDbMaxOpenConns(), "MongoDB maximum number of open connections\nDotenv variable: DB_MAX_OPEN_CONNS\n")
 	flag.IntVar(&c.Db.MaxIdleConns, "db-max-idle-conns", c.defaultDbMaxIdleConns(), "MongoDB maximum number of idle connections\nDotenv variable: DB_MAX_IDLE_CONNS\n")
 	flag.StringVar(&c.Db.MaxIdleTime, "db-max-idle-time", c.defaultDbMaxIdleTime(), "MongoDB maximumn idle time\nDotenv variable: DB_MAX_IDLE_TIME\n")
 
+	flag.StringVar(&c.Broker.Driver, "broker", c.defaultBrokerDriver(), "WebSocket broker, redis to run several nodes (memory|redis)\nDotenv variable: BROKER\n")
+	flag.StringVar(&c.Broker.RedisUrl, "redis-url", c.defaultRedisUrl(), "Redis URL of the broker\nDotenv variable: REDIS_URL\n")
+
+	flag.DurationVar(&c.Ws.PingInterval, "ws-ping-interval", c.defaultWsPingInterval(), "Interval between the pings sent to sockets\nDotenv variable: WS_PING_INTERVAL\n")
+	flag.DurationVar(&c.Ws.PongWait, "ws-pong-wait", c.defaultWsPongWait(), "Time a socket has to answer a ping before it is disconnected\nDotenv variable: WS_PONG_WAIT\n")
+	flag.DurationVar(&c.Ws.WriteWait, "ws-write-wait", c.defaultWsWriteWait(), "Time allowed to write a frame to a socket\nDotenv variable: WS_WRITE_WAIT\n")
+	flag.Int64Var(&c.Ws.MaxMessageSize, "ws-max-message-size", c.defaultWsMaxMessageSize(), "Maximum size in bytes of the frames sent by sockets\nDotenv variable: WS_MAX_MESSAGE_SIZE\n")
+
+	flag.StringVar(&c.Mail.Driver, "mailer", c.defaultMailDriver(), "Mailer, file to write emails to a directory instead of sending them (smtp|file)\nDotenv variable: MAILER\n")
+	flag.StringVar(&c.Mail.From, "mail-from", c.defaultMailFrom(), "Sender address of emails\nDotenv variable: MAIL_FROM\n")
+	flag.StringVar(&c.Mail.Dir, "mail-dir", c.defaultMailDir(), "Directory the file mailer writes emails to\nDotenv variable: MAIL_DIR\n")
+	flag.StringVar(&c.Mail.SmtpHost, "smtp-host", c.defaultSmtpHost(), "SMTP server host\nDotenv variable: SMTP_HOST\n")
+	flag.IntVar(&c.Mail.SmtpPort, "smtp-port", c.defaultSmtpPort(), "SMTP server port\nDotenv variable: SMTP_PORT\n")
+	flag.StringVar(&c.Mail.SmtpUsername, "smtp-username", c.defaultSmtpUsername(), "SMTP username, if the server requires authentication\nDotenv variable: SMTP_USERNAME\n")
+	flag.StringVar(&c.Mail.SmtpPassword, "smtp-password", c.defaultSmtpPassword(), "SMTP password\nDotenv variable: SMTP_PASSWORD\n")
+
 	flag.Parse()
 }
 
 // Validate ensures required flags or environment variables are present
 func (c *Config) Validate() error {
-	if c.Db.Uri == "" {
-		return errors.New("the 'db-uri' flag is required")
+	if c.ShutdownTimeout <= 0 {
+		return errors.New("the 'shutdown-timeout' flag must be positive")
+	}
+
+	switch c.Db.Driver {
+	case DbDriverMemory:
+		// the in-memory repositories need no connection details
+
+	case DbDriverMongo:
+		if c.Db.Uri == "" {
+			return errors.New("the 'db-uri' flag is required")
+		}
+
+		if c.Db.Name == "" {
+			return errors.New("the 'db-name flag is required")
+		}
+
+	default:
+		return errors.New("the 'db-driver' flag must be either 'mongo' or 'memory'")
+	}
+
+	switch c.Broker.Driver {
+	case BrokerMemory:
+		// the in-process broker needs no connection details
+
+	case BrokerRedis:
+		if c.Broker.RedisUrl == "" {
+			return errors.New("the 'redis-url' flag is required")
+		}
+
+	default:
+		return errors.New("the 'broker' flag must be either 'memory' or 'redis'")
+	}
+
+	if c.Ws.PongWait <= 0 || c.Ws.WriteWait <= 0 || c.Ws.MaxMessageSize <= 0 {
+		return errors.New("the 'ws-pong-wait', 'ws-write-wait' and 'ws-max-message-size' flags must be positive")
+	}
+
+	// sockets must be pinged before their pong wait runs out
+	if c.Ws.PingInterval <= 0 || c.Ws.PingInterval >= c.Ws.PongWait {
+		return errors.New("the 'ws-ping-interval' flag must be positive and less than 'ws-pong-wait'")
+	}
+
+	if c.AppUrl == "" {
+		return errors.New("the 'app-url' flag is required")
 	}
 
-	if c.Db.Name == "" {
-		return errors.New("the 'db-name flag is required")
+	if c.Mail.From == "" {
+		return errors.New("the 'mail-from' flag is required")
+	}
+
+	switch c.Mail.Driver {
+	case MailerFile:
+		if c.Mail.Dir == "" {
+			return errors.New("the 'mail-dir' flag is required")
+		}
+
+	case MailerSmtp:
+		if c.Mail.SmtpHost == "" {
+			return errors.New("the 'smtp-host' flag is required")
+		}
+
+	default:
+		return errors.New("the 'mailer' flag must be either 'smtp' or 'file'")
 	}
 
 	return nil
@@ -81,6 +210,27 @@ func (c *Config) defaultJwtSecret() string {
 	return defaultSecret
 }
 
+func (c *Config) defaultShutdownTimeout() time.Duration {
+	const defaultTimeout = 30 * time.Second
+
+	if timeoutEnv, exists := os.LookupEnv("SHUTDOWN_TIMEOUT"); exists {
+		timeout, err := time.ParseDuration(timeoutEnv)
+		if err == nil {
+			return timeout
+		}
+	}
+	return defaultTimeout
+}
+
+func (c *Config) defaultDbDriver() string {
+	const defaultDriver = DbDriverMongo
+
+	if driver, exists := os.LookupEnv("DB_DRIVER"); exists {
+		return driver
+	}
+	return defaultDriver
+}
+
 func (c *Config) defaultDbUri() string {
 	const defaultUri = ""
 	if uri, exists := os.LookupEnv("DB_URI"); exists {
@@ -130,3 +280,144 @@ func (c *Config) defaultDbMaxIdleTime() string {
 	}
 	return defMaxIdleTime
 }
+
+func (c *Config) defaultBrokerDriver() string {
+	const defaultDriver = BrokerMemory
+
+	if driver, exists := os.LookupEnv("BROKER"); exists {
+		return driver
+	}
+	return defaultDriver
+}
+
+func (c *Config) defaultRedisUrl() string {
+	const defaultUrl = ""
+
+	if url, exists := os.LookupEnv("REDIS_URL"); exists {
+		return url
+	}
+	return defaultUrl
+}
+
+func (c *Config) defaultWsPingInterval() time.Duration {
+	const defaultInterval = 54 * time.Second
+
+	if intervalEnv, exists := os.LookupEnv("WS_PING_INTERVAL"); exists {
+		interval, err := time.ParseDuration(intervalEnv)
+		if err == nil {
+			return interval
+		}
+	}
+	return defaultInterval
+}
+
+func (c *Config) defaultWsPongWait() time.Duration {
+	const defaultWait = 60 * time.Second
+
+	if waitEnv, exists := os.LookupEnv("WS_PONG_WAIT"); exists {
+		wait, err := time.ParseDuration(waitEnv)
+		if err == nil {
+			return wait
+		}
+	}
+	return defaultWait
+}
+
+func (c *Config) defaultWsWriteWait() time.Duration {
+	const defaultWait = 10 * time.Second
+
+	if waitEnv, exists := os.LookupEnv("WS_WRITE_WAIT"); exists {
+		wait, err := time.ParseDuration(waitEnv)
+		if err == nil {
+			return wait
+		}
+	}
+	return defaultWait
+}
+
+func (c *Config) defaultWsMaxMessageSize() int64 {
+	const defaultSize = 64 * 1024
+
+	if sizeEnv, exists := os.LookupEnv("WS_MAX_MESSAGE_SIZE"); exists {
+		size, err := strconv.ParseInt(sizeEnv, 10, 64)
+		if err == nil {
+			return size
+		}
+	}
+	return defaultSize
+}
+
+func (c *Config) defaultAppUrl() string {
+	const defaultUrl = "http://localhost:3000"
+
+	if url, exists := os.LookupEnv("APP_URL"); exists {
+		return url
+	}
+	return defaultUrl
+}
+
+func (c *Config) defaultMailDriver() string {
+	const defaultDriver = MailerFile
+
+	if driver, exists := os.LookupEnv("MAILER"); exists {
+		return driver
+	}
+	return defaultDriver
+}
+
+func (c *Config) defaultMailFrom() string {
+	const defaultFrom = "no-reply@localhost"
+
+	if from, exists := os.LookupEnv("MAIL_FROM"); exists {
+		return from
+	}
+	return defaultFrom
+}
+
+func (c *Config) defaultMailDir() string {
+	const defaultDir = "mail"
+
+	if dir, exists := os.LookupEnv("MAIL_DIR"); exists {
+		return dir
+	}
+	return defaultDir
+}
+
+func (c *Config) defaultSmtpHost() string {
+	const defaultHost = ""
+
+	if host, exists := os.LookupEnv("SMTP_HOST"); exists {
+		return host
+	}
+	return defaultHost
+}
+
+func (c *Config) defaultSmtpPort() int {
+	const defaultPort = 587
+
+	if portEnv, exists := os.LookupEnv("SMTP_PORT"); exists {
+		port, err := strconv.Atoi(portEnv)
+		if err == nil {
+			return port
+		}
+	}
+	return defaultPort
+}
+
+func (c *Config) defaultSmtpUsername() string {
+	const defaultUsername = ""
+
+	if username, exists := os.LookupEnv("SMTP_USERNAME"); exists {
+		return username
+	}
+	return defaultUsername
+}
+
+func (c *Config) defaultSmtpPassword() string {
+	const defaultPassword = ""
+
+	if password, exists := os.LookupEnv("SMTP_PASSWORD"); exists {
+		return password
+	}
+	return defaultPassword
+}
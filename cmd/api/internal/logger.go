@@ -0,0 +1,15 @@
+package internal
+
+import "go.uber.org/zap"
+
+// NewLogger returns a leveled logger writing JSON in production and human-readable text otherwise.
+// Debug entries are only written outside of production, and stack traces never, as entries carry their context.
+func NewLogger(env string) (*zap.Logger, error) {
+	config := zap.NewDevelopmentConfig()
+	if env == "production" {
+		config = zap.NewProductionConfig()
+	}
+	config.DisableStacktrace = true
+
+	return config.Build()
+}
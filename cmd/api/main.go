@@ -2,40 +2,144 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"github.com/Mutay1/chat-backend/cmd/api/internal"
+	"github.com/Mutay1/chat-backend/domain/broker"
+	"github.com/Mutay1/chat-backend/domain/mailer"
+	"github.com/Mutay1/chat-backend/domain/presence"
+	"github.com/Mutay1/chat-backend/domain/repository"
+	"github.com/Mutay1/chat-backend/infrastructure/database"
+	"github.com/Mutay1/chat-backend/infrastructure/file"
+	"github.com/Mutay1/chat-backend/infrastructure/memory"
+	"github.com/Mutay1/chat-backend/infrastructure/metrics"
+	"github.com/Mutay1/chat-backend/infrastructure/redis"
+	"github.com/Mutay1/chat-backend/infrastructure/smtp"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"go.uber.org/zap"
 	"log"
+	"os"
+)
+
+// Build information, injected at build time with
+//
+//	go build -ldflags "-X main.version=1.0.0 -X main.commit=$(git rev-parse HEAD) -X main.buildTime=$(date -u +%FT%TZ)" ./cmd/api
+var (
+	version   = "development"
+	commit    = "unknown"
+	buildTime = "unknown"
 )
 
 func main() {
-	// load environment variables from dotenv file
-	if err := godotenv.Load(); err != nil {
+	// load environment variables from dotenv file, if any
+	if err := godotenv.Load(); err != nil && !os.IsNotExist(err) {
 		log.Fatalf("error loading dotenv file: %s\n", err.Error())
 	}
 
 	// setup server configurations
-	config := internal.Config{}
+	config := internal.Config{
+		Version:   version,
+		Commit:    commit,
+		BuildTime: buildTime,
+	}
 	config.Parse()
+
+	// display build information instead of serving if requested
+	if config.DisplayVersion {
+		fmt.Printf("Version:\t%s\n", config.Version)
+		fmt.Printf("Commit:\t\t%s\n", config.Commit)
+		fmt.Printf("Build time:\t%s\n", config.BuildTime)
+		return
+	}
+
 	if err := config.Validate(); err != nil {
 		log.Fatalln(err)
 	}
 
+	// setup structured logging, which handlers fall back to through the global logger
+	logger, err := internal.NewLogger(config.Env)
+	if err != nil {
+		log.Fatalf("logger: %s\n", err.Error())
+	}
+	defer logger.Sync()
+	zap.ReplaceGlobals(logger)
+
 	// set Gin to release mode on production
 	if config.Env == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// open database connection
-	db, err := openDb(config)
-	if err != nil {
-		log.Fatalf("database connection: %s\n", err.Error())
+	var repositories repository.Repositories
+	switch config.Db.Driver {
+	case internal.DbDriverMemory:
+		repositories = memory.NewRepositories()
+		logger.Warn("using in-memory repositories, data will be lost on exit")
+
+	default:
+		// open database connection
+		db, err := openDb(config)
+		if err != nil {
+			logger.Fatal("database connection", zap.Error(err))
+		}
+		defer db.Client().Disconnect(context.Background())
+		logger.Info("database connection established")
+
+		// run migrations instead of serving if requested
+		if config.Migrate {
+			if err := database.Migrate(db, logger); err != nil {
+				logger.Fatal("database migration", zap.Error(err))
+			}
+			logger.Info("database migration completed")
+			return
+		}
+
+		repositories = mongoRepositories(db)
+	}
+
+	// measure the latency of user lookups, which every authenticated request makes
+	repositories.Users = metrics.UserRepository{Users: repositories.Users}
+
+	// presence is shared through the same backend as frames, so it spans every node the broker does
+	var messageBroker broker.Broker
+	var presenceTracker presence.Tracker
+	switch config.Broker.Driver {
+	case internal.BrokerRedis:
+		redisBroker, err := redis.NewBroker(config.Broker.RedisUrl)
+		if err != nil {
+			logger.Fatal("broker connection", zap.Error(err))
+		}
+		messageBroker = redisBroker
+
+		redisPresence, err := redis.NewPresence(config.Broker.RedisUrl)
+		if err != nil {
+			logger.Fatal("presence connection", zap.Error(err))
+		}
+		defer redisPresence.Close()
+		presenceTracker = redisPresence
+		logger.Info("broker connection established")
+
+	default:
+		messageBroker = memory.NewBroker()
+		presenceTracker = memory.NewPresence()
+	}
+	defer messageBroker.Close()
+
+	var messageMailer mailer.Mailer
+	switch config.Mail.Driver {
+	case internal.MailerSmtp:
+		messageMailer = smtp.NewMailer(config.Mail.SmtpHost, config.Mail.SmtpPort, config.Mail.SmtpUsername, config.Mail.SmtpPassword, config.Mail.From)
+
+	default:
+		fileMailer, err := file.NewMailer(config.Mail.Dir, config.Mail.From)
+		if err != nil {
+			logger.Fatal("mailer", zap.Error(err))
+		}
+		messageMailer = fileMailer
+		logger.Warn("writing emails to a directory instead of sending them", zap.String("dir", config.Mail.Dir))
 	}
-	defer db.Client().Disconnect(context.Background())
-	log.Println("database connection established")
 
 	// start server
-	if err := serveApp(config, db); err != nil {
-		log.Fatalln(err)
+	if err := serveApp(config, repositories, messageBroker, presenceTracker, messageMailer, logger); err != nil {
+		logger.Fatal("server", zap.Error(err))
 	}
 }
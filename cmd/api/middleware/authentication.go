@@ -2,7 +2,6 @@ package middleware
 
 import (
 	"errors"
-	"fmt"
 	"github.com/Mutay1/chat-backend/cmd/api/internal"
 	"github.com/Mutay1/chat-backend/domain/repository"
 	"net/http"
@@ -20,20 +19,14 @@ func Authentication(app internal.Application) gin.HandlerFunc {
 		// check for the existence of the Authorization header
 		clientToken := ctx.Request.Header.Get("Authorization")
 		if clientToken == "" {
-			ctx.AbortWithStatusJSON(
-				http.StatusUnauthorized,
-				gin.H{"error": fmt.Sprintf("no Authorization header provided")},
-			)
+			helper.AbortWithError(ctx, http.StatusUnauthorized, helper.ErrorUnauthorized, "no Authorization header provided")
 			return
 		}
 
 		// validate JWT if it exists
 		claims, err := helper.ValidateToken(app.Config.JwtSecret, clientToken)
 		if err != nil {
-			ctx.AbortWithStatusJSON(
-				http.StatusUnauthorized,
-				gin.H{"error": err.Error()},
-			)
+			helper.AbortWithError(ctx, http.StatusUnauthorized, helper.ErrorUnauthorized, err.Error())
 			return
 		}
 
@@ -42,10 +35,7 @@ func Authentication(app internal.Application) gin.HandlerFunc {
 		if err != nil {
 			switch {
 			case errors.Is(err, repository.ErrRecordNotFound):
-				ctx.AbortWithStatusJSON(
-					http.StatusUnprocessableEntity,
-					gin.H{"error": "user not found"},
-				)
+				helper.AbortWithError(ctx, http.StatusUnauthorized, helper.ErrorUnauthorized, "user not found")
 
 			default:
 				helper.HandleInternalServerError(ctx, err)
@@ -54,8 +44,30 @@ func Authentication(app internal.Application) gin.HandlerFunc {
 			return
 		}
 
-		// set user ID and email in context for further use
+		// reject tokens of revoked sessions, tokens issued before sessions existed have none
+		if claims.SessionID != "" {
+			session, err := app.Repositories.Sessions.GetById(claims.SessionID)
+			if err != nil {
+				switch {
+				case errors.Is(err, repository.ErrRecordNotFound):
+					helper.AbortWithError(ctx, http.StatusUnauthorized, helper.ErrorUnauthorized, "session revoked or expired")
+
+				default:
+					helper.HandleInternalServerError(ctx, err)
+				}
+
+				return
+			}
+
+			if session.UserID != user.UserID {
+				helper.AbortWithError(ctx, http.StatusUnauthorized, helper.ErrorUnauthorized, "session revoked or expired")
+				return
+			}
+		}
+
+		// set user, session ID and email in context for further use
 		ctx.Set("uid", user.UserID)
+		ctx.Set("sid", claims.SessionID)
 		ctx.Set("email", *user.Email)
 		ctx.Next()
 	}
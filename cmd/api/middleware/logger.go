@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"time"
+
+	helper "github.com/Mutay1/chat-backend/helpers"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Logger writes an entry for every request once handled, with the logger of the request.
+// Query strings are left out, as the WebSocket route accepts access tokens in them.
+func Logger() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		fields := []zap.Field{
+			zap.String("method", ctx.Request.Method),
+			zap.String("path", ctx.Request.URL.Path),
+			zap.String("route", ctx.FullPath()),
+			zap.Int("status", ctx.Writer.Status()),
+			zap.Duration("latency", time.Since(start)),
+			zap.String("client_ip", ctx.ClientIP()),
+		}
+		if userId := ctx.GetString("uid"); userId != "" {
+			fields = append(fields, zap.String("user_id", userId))
+		}
+
+		// client errors are expected, unlike server errors
+		if ctx.Writer.Status() >= 500 {
+			helper.Logger(ctx).Error("request", fields...)
+			return
+		}
+		helper.Logger(ctx).Info("request", fields...)
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Mutay1/chat-backend/infrastructure/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// standardMethods are the request methods given a series of their own.
+var standardMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// Metrics counts and times the requests, labelled by route template so paths with IDs share their series.
+// Requests matching no route are grouped under "unmatched", and those with a non-standard method under "other".
+func Metrics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := ctx.Request.Method
+		if !standardMethods[method] {
+			method = "other"
+		}
+
+		metrics.HttpRequests.WithLabelValues(method, route, strconv.Itoa(ctx.Writer.Status())).Inc()
+		metrics.HttpRequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
+	helper "github.com/Mutay1/chat-backend/helpers"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader carries the ID of a request, from the client or a proxy in front of the server, and back.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDKey is the context key of the request ID.
+const RequestIDKey = "requestID"
+
+// validRequestId restricts the request IDs accepted from clients, so they cannot forge log entries.
+var validRequestId = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID propagates the X-Request-ID header of the request, or a generated ID if it has none or an invalid one,
+// to the response and to the logger of the request.
+func RequestID(app internal.Application) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestId := ctx.GetHeader(RequestIDHeader)
+		if !validRequestId.MatchString(requestId) {
+			requestId = newRequestId()
+		}
+
+		ctx.Set(RequestIDKey, requestId)
+		ctx.Set(helper.LoggerKey, app.Logger.With(zap.String("request_id", requestId)))
+		ctx.Header(RequestIDHeader, requestId)
+
+		ctx.Next()
+	}
+}
+
+// newRequestId generates a random 128-bit request ID.
+func newRequestId() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
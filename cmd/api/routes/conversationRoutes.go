@@ -0,0 +1,12 @@
+package routes
+
+import (
+	controller "github.com/Mutay1/chat-backend/cmd/api/controllers"
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
+	"github.com/gin-gonic/gin"
+)
+
+// ConversationRoutes function
+func ConversationRoutes(app internal.Application, incomingRoutes *gin.RouterGroup) {
+	incomingRoutes.GET("/conversations/:friendId/messages", controller.GetConversationMessages(app))
+}
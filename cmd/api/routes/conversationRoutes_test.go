@@ -0,0 +1,158 @@
+package routes_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestConversationMessagesPagination(t *testing.T) {
+	t.Parallel()
+
+	sender := signUp(t, "pager")
+	recipient := signUp(t, "paged")
+	befriend(t, sender, recipient)
+
+	conn := dialSocket(t, sender)
+	for i := 0; i < 5; i++ {
+		sendMessage(t, conn, map[string]interface{}{
+			"recipientID": recipient.ID,
+			"content":     fmt.Sprintf("message %d", i),
+		})
+	}
+
+	type page struct {
+		Messages []struct {
+			Content string `json:"content"`
+		} `json:"messages"`
+		NextCursor *string `json:"nextCursor"`
+	}
+	path := "/conversations/" + sender.ID + "/messages?limit=3"
+
+	var first page
+	eventually(t, func() bool {
+		res, body := request(t, http.MethodGet, path, recipient.Token, nil)
+		decode(t, body, &first)
+		return res.StatusCode == http.StatusOK && first.NextCursor != nil
+	})
+	if len(first.Messages) != 3 || first.Messages[0].Content != "message 2" || first.Messages[2].Content != "message 4" {
+		t.Fatalf("first page: expected the latest 3 messages, got %+v", first.Messages)
+	}
+
+	var second page
+	res, body := request(t, http.MethodGet, path+"&before="+*first.NextCursor, recipient.Token, nil)
+	decode(t, body, &second)
+	if res.StatusCode != http.StatusOK || len(second.Messages) != 2 || second.NextCursor != nil {
+		t.Fatalf("second page: expected the 2 oldest messages, got %d: %s", res.StatusCode, body)
+	}
+
+	// the unread count is summarised on the friends list
+	var friends []struct {
+		UnreadCount int `json:"unreadCount"`
+		LastMessage struct {
+			Content string `json:"content"`
+		} `json:"lastMessage"`
+	}
+	res, body = request(t, http.MethodGet, "/friends", recipient.Token, nil)
+	decode(t, body, &friends)
+	if res.StatusCode != http.StatusOK || len(friends) != 1 || friends[0].UnreadCount != 5 || friends[0].LastMessage.Content != "message 4" {
+		t.Fatalf("friends: expected 5 unread messages, got %d: %s", res.StatusCode, body)
+	}
+
+	t.Run("strangers", func(t *testing.T) {
+		stranger := signUp(t, "stranger")
+		res, body := request(t, http.MethodGet, "/conversations/"+sender.ID+"/messages", stranger.Token, nil)
+		if res.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNotFound, res.StatusCode, body)
+		}
+	})
+
+	t.Run("invalid limit", func(t *testing.T) {
+		res, body := request(t, http.MethodGet, "/conversations/"+sender.ID+"/messages?limit=0", recipient.Token, nil)
+		if res.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, res.StatusCode, body)
+		}
+	})
+}
+
+func TestConversationMessagesAreStoredOnce(t *testing.T) {
+	t.Parallel()
+
+	sender := signUp(t, "storer")
+	recipient := signUp(t, "stored")
+	befriend(t, sender, recipient)
+
+	conn := dialSocket(t, sender)
+	acks := []frame{
+		sendMessage(t, conn, map[string]interface{}{"recipientID": recipient.ID, "content": "first"}),
+		sendMessage(t, conn, map[string]interface{}{"recipientID": recipient.ID, "content": "second"}),
+	}
+	if acks[0].Payload["messageID"] == acks[1].Payload["messageID"] {
+		t.Fatalf("expected every message to get its own ID, got %+v", acks)
+	}
+
+	// both members page through the same messages, which belong to one conversation
+	type page struct {
+		Messages []struct {
+			ID             string `json:"id"`
+			ConversationID string `json:"conversationID"`
+			Sender         string `json:"sender"`
+		} `json:"messages"`
+	}
+	var senderPage, recipientPage page
+	res, body := request(t, http.MethodGet, "/conversations/"+recipient.ID+"/messages", sender.Token, nil)
+	decode(t, body, &senderPage)
+	if res.StatusCode != http.StatusOK || len(senderPage.Messages) != 2 {
+		t.Fatalf("sender history: expected 2 messages, got %d: %s", res.StatusCode, body)
+	}
+	res, body = request(t, http.MethodGet, "/conversations/"+sender.ID+"/messages", recipient.Token, nil)
+	decode(t, body, &recipientPage)
+	if res.StatusCode != http.StatusOK || len(recipientPage.Messages) != 2 {
+		t.Fatalf("recipient history: expected 2 messages, got %d: %s", res.StatusCode, body)
+	}
+	for i, message := range senderPage.Messages {
+		if message.ID != acks[i].Payload["messageID"] || message.ID != recipientPage.Messages[i].ID ||
+			message.ConversationID != acks[i].Payload["conversationID"] || message.Sender != sender.ID {
+			t.Fatalf("expected message %d to be stored once, got %+v and %+v", i, message, recipientPage.Messages[i])
+		}
+	}
+
+	// friendships no longer embed their messages
+	var friends []map[string]interface{}
+	res, body = request(t, http.MethodGet, "/friends", sender.Token, nil)
+	decode(t, body, &friends)
+	if res.StatusCode != http.StatusOK || len(friends) != 1 || friends[0]["messages"] != nil {
+		t.Fatalf("friends: expected no embedded messages, got %d: %s", res.StatusCode, body)
+	}
+}
+
+func TestConversationMessagesEmpty(t *testing.T) {
+	t.Parallel()
+
+	user := signUp(t, "quiet")
+	friend := signUp(t, "silent")
+	befriend(t, user, friend)
+
+	page := struct {
+		Messages   []interface{} `json:"messages"`
+		NextCursor *string       `json:"nextCursor"`
+	}{}
+	res, body := request(t, http.MethodGet, "/conversations/"+friend.ID+"/messages", user.Token, nil)
+	decode(t, body, &page)
+	if res.StatusCode != http.StatusOK || page.Messages == nil || len(page.Messages) != 0 || page.NextCursor != nil {
+		t.Fatalf("expected an empty last page, got %d: %s", res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodGet, "/conversations/"+friend.ID+"/messages?before=not-a-cursor", user.Token, nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid cursor: expected status %d, got %d: %s", http.StatusBadRequest, res.StatusCode, body)
+	}
+
+	// friends are summarised without a last message until one is sent
+	var friends []map[string]interface{}
+	res, body = request(t, http.MethodGet, "/friends", user.Token, nil)
+	decode(t, body, &friends)
+	if res.StatusCode != http.StatusOK || len(friends) != 1 || friends[0]["lastMessage"] != nil || friends[0]["unreadCount"] != float64(0) {
+		t.Fatalf("friends: expected no messages to summarise, got %d: %s", res.StatusCode, body)
+	}
+}
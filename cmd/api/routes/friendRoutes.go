@@ -2,10 +2,12 @@ package routes
 
 import (
 	controller "github.com/Mutay1/chat-backend/cmd/api/controllers"
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
 	"github.com/gin-gonic/gin"
 )
 
 //FriendRoutes Function
-func FriendRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/friends", controller.GetFriends())
+func FriendRoutes(app internal.Application, incomingRoutes *gin.RouterGroup) {
+	incomingRoutes.GET("/friends", controller.GetFriends(app))
+	incomingRoutes.GET("/friends/presence", controller.GetFriendsPresence(app))
 }
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	controller "github.com/Mutay1/chat-backend/cmd/api/controllers"
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
+	"github.com/gin-gonic/gin"
+)
+
+// GroupRoutes function
+func GroupRoutes(app internal.Application, incomingRoutes *gin.RouterGroup) {
+	incomingRoutes.POST("/groups", controller.CreateGroup(app))
+	incomingRoutes.GET("/groups", controller.GetGroups(app))
+	incomingRoutes.GET("/groups/:groupId", controller.GetGroup(app))
+	incomingRoutes.PATCH("/groups/:groupId", controller.UpdateGroup(app))
+	incomingRoutes.POST("/groups/:groupId/avatar", controller.UpdateGroupAvatar(app))
+	incomingRoutes.POST("/groups/:groupId/leave", controller.LeaveGroup(app))
+	incomingRoutes.GET("/groups/:groupId/messages", controller.GetGroupMessages(app))
+	incomingRoutes.POST("/groups/:groupId/members", controller.AddGroupMember(app))
+	incomingRoutes.DELETE("/groups/:groupId/members/:userId", controller.RemoveGroupMember(app))
+	incomingRoutes.PATCH("/groups/:groupId/members/:userId", controller.UpdateGroupMemberRole(app))
+}
@@ -0,0 +1,197 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// groupMembers maps the user IDs of a group's members to their roles.
+func groupMembers(t *testing.T, user testUser, groupId string) map[string]string {
+	t.Helper()
+
+	group := struct {
+		Members []struct {
+			UserID string `json:"userID"`
+			Role   string `json:"role"`
+		} `json:"members"`
+	}{}
+	res, body := request(t, http.MethodGet, "/groups/"+groupId, user.Token, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("get group: got status %d: %s", res.StatusCode, body)
+	}
+	decode(t, body, &group)
+
+	roles := map[string]string{}
+	for _, member := range group.Members {
+		roles[member.UserID] = member.Role
+	}
+	return roles
+}
+
+func TestGroupLifecycle(t *testing.T) {
+	t.Parallel()
+
+	owner := signUp(t, "owner")
+	admin := signUp(t, "admin")
+	member := signUp(t, "member")
+	stranger := signUp(t, "outsider")
+	befriend(t, owner, admin)
+	befriend(t, owner, member)
+
+	// only friends can be added
+	res, body := request(t, http.MethodPost, "/groups", owner.Token, gin.H{
+		"name":    "Book club",
+		"members": []string{admin.ID, stranger.ID},
+	})
+	if res.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("create with stranger: expected status %d, got %d: %s", http.StatusUnprocessableEntity, res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodPost, "/groups", owner.Token, gin.H{
+		"name":    "Book club",
+		"members": []string{admin.ID},
+	})
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create: expected status %d, got %d: %s", http.StatusCreated, res.StatusCode, body)
+	}
+	group := struct {
+		ID string `json:"id"`
+	}{}
+	decode(t, body, &group)
+
+	res, body = request(t, http.MethodGet, "/groups/"+group.ID, stranger.Token, nil)
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("get as stranger: expected status %d, got %d: %s", http.StatusNotFound, res.StatusCode, body)
+	}
+
+	// members cannot manage the group until promoted
+	res, body = request(t, http.MethodPost, "/groups/"+group.ID+"/members", admin.Token, gin.H{"userID": member.ID})
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("add as member: expected status %d, got %d: %s", http.StatusForbidden, res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodPatch, "/groups/"+group.ID+"/members/"+admin.ID, owner.Token, gin.H{"role": "admin"})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("promote: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodPost, "/groups/"+group.ID+"/members", owner.Token, gin.H{"userID": member.ID})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("add: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+
+	// members are not updated along with the group
+	res, body = request(t, http.MethodPatch, "/groups/"+group.ID, admin.Token, gin.H{"name": "Reading club", "members": []string{}})
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("rename with members: expected status %d, got %d: %s", http.StatusBadRequest, res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodPatch, "/groups/"+group.ID, admin.Token, gin.H{"name": "Reading club"})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("rename: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+
+	// admins cannot remove the owner
+	res, body = request(t, http.MethodDelete, "/groups/"+group.ID+"/members/"+owner.ID, admin.Token, nil)
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("remove owner: expected status %d, got %d: %s", http.StatusForbidden, res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodDelete, "/groups/"+group.ID+"/members/"+member.ID, admin.Token, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("remove: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+
+	// ownership passes to the admin when the owner leaves
+	res, body = request(t, http.MethodPost, "/groups/"+group.ID+"/leave", owner.Token, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("leave: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+
+	roles := groupMembers(t, admin, group.ID)
+	if len(roles) != 1 || roles[admin.ID] != "owner" {
+		t.Fatalf("expected the admin to be the sole owner, got %v", roles)
+	}
+
+	var groups []struct {
+		Name string `json:"name"`
+	}
+	res, body = request(t, http.MethodGet, "/groups", admin.Token, nil)
+	decode(t, body, &groups)
+	if res.StatusCode != http.StatusOK || len(groups) != 1 || groups[0].Name != "Reading club" {
+		t.Fatalf("list: expected the renamed group, got %d: %s", res.StatusCode, body)
+	}
+}
+
+func TestGroupMessages(t *testing.T) {
+	t.Parallel()
+
+	owner := signUp(t, "groupsender")
+	first := signUp(t, "groupreader")
+	second := signUp(t, "groupreader")
+	befriend(t, owner, first)
+	befriend(t, owner, second)
+
+	res, body := request(t, http.MethodPost, "/groups", owner.Token, gin.H{
+		"name":    "Chatters",
+		"members": []string{first.ID, second.ID},
+	})
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create: expected status %d, got %d: %s", http.StatusCreated, res.StatusCode, body)
+	}
+	group := struct {
+		ID string `json:"id"`
+	}{}
+	decode(t, body, &group)
+
+	ownerConn := dialSocket(t, owner)
+	firstConn := dialSocket(t, first)
+	secondConn := dialSocket(t, second)
+
+	writeFrame(t, ownerConn, "message.send", "hello-everyone", gin.H{
+		"groupID": group.ID,
+		"content": "hello everyone",
+	})
+
+	// every member's sockets receive the message
+	for _, conn := range []*websocket.Conn{ownerConn, firstConn, secondConn} {
+		frame := readFrameOf(t, conn, "message.new")
+		if frame.Type != "message.new" || frame.Payload["content"] != "hello everyone" || frame.Payload["groupID"] != group.ID {
+			t.Fatalf("expected the group message, got %+v", frame)
+		}
+	}
+
+	// and unread state is tracked per member
+	unread := func(user testUser) float64 {
+		var groups []struct {
+			UnreadCount float64 `json:"unreadCount"`
+		}
+		_, body := request(t, http.MethodGet, "/groups", user.Token, nil)
+		decode(t, body, &groups)
+		if len(groups) != 1 {
+			t.Fatalf("expected a single group, got %s", body)
+		}
+		return groups[0].UnreadCount
+	}
+	eventually(t, func() bool { return unread(first) == 1 && unread(second) == 1 })
+
+	writeFrame(t, firstConn, "receipt.update", "", gin.H{
+		"groupID": group.ID,
+		"status":  "read",
+	})
+	eventually(t, func() bool { return unread(first) == 0 && unread(second) == 1 })
+
+	page := struct {
+		Messages []struct {
+			Content string `json:"content"`
+		} `json:"messages"`
+	}{}
+	res, body = request(t, http.MethodGet, "/groups/"+group.ID+"/messages", second.Token, nil)
+	decode(t, body, &page)
+	if res.StatusCode != http.StatusOK || len(page.Messages) != 1 || page.Messages[0].Content != "hello everyone" {
+		t.Fatalf("history: expected the group message, got %d: %s", res.StatusCode, body)
+	}
+}
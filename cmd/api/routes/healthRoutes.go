@@ -0,0 +1,17 @@
+package routes
+
+import (
+	controller "github.com/Mutay1/chat-backend/cmd/api/controllers"
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// HealthRoutes function
+// The probes and metrics are public, as orchestrators and scrapers call them without credentials.
+func HealthRoutes(app internal.Application, incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/healthz", controller.Healthz())
+	incomingRoutes.GET("/readyz", controller.Readyz(app))
+	incomingRoutes.GET("/version", controller.Version(app))
+	incomingRoutes.GET("/metrics", gin.WrapH(promhttp.Handler()))
+}
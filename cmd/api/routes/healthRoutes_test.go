@@ -0,0 +1,114 @@
+package routes_test
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// metric returns the value of a series exposed on /metrics, or 0 if it is not exposed yet.
+// The series is given as in the exposition format, i.e. its name followed by its labels in alphabetical order.
+func metric(t *testing.T, series string) float64 {
+	t.Helper()
+
+	res, body := request(t, http.MethodGet, "/metrics", "", nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("metrics: got status %d: %s", res.StatusCode, body)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		if value := strings.TrimPrefix(scanner.Text(), series+" "); value != scanner.Text() {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatalf("metrics: invalid value of %s: %s", series, value)
+			}
+			return parsed
+		}
+	}
+	return 0
+}
+
+func TestHealthProbes(t *testing.T) {
+	t.Parallel()
+
+	res, body := request(t, http.MethodGet, "/healthz", "", nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("healthz: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+
+	// the hub starts asynchronously
+	eventually(t, func() bool {
+		res, _ := request(t, http.MethodGet, "/readyz", "", nil)
+		return res.StatusCode == http.StatusOK
+	})
+
+	build := map[string]string{}
+	res, body = request(t, http.MethodGet, "/version", "", nil)
+	decode(t, body, &build)
+	if res.StatusCode != http.StatusOK || build["version"] != "test" {
+		t.Fatalf("version: expected the configured version, got %d: %s", res.StatusCode, body)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	before := metric(t, `chat_http_requests_total{method="GET",route="/healthz",status="200"}`)
+	request(t, http.MethodGet, "/healthz", "", nil)
+	if after := metric(t, `chat_http_requests_total{method="GET",route="/healthz",status="200"}`); after <= before {
+		t.Fatalf("expected the request to be counted, got %v then %v", before, after)
+	}
+
+	// requests are labelled by route rather than path
+	user := signUp(t, "measured")
+	request(t, http.MethodGet, "/conversations/"+user.ID+"/messages", user.Token, nil)
+	if metric(t, `chat_http_requests_total{method="GET",route="/conversations/:friendId/messages",status="404"}`) == 0 {
+		t.Fatal("expected the request to be counted under its route")
+	}
+
+	// arbitrary methods share a series, like paths matching no route
+	request(t, "BREW", "/coffee-pot", "", nil)
+	if metric(t, `chat_http_requests_total{method="other",route="unmatched",status="404"}`) == 0 {
+		t.Fatal("expected the request to be counted under other methods")
+	}
+
+	if metric(t, `chat_repository_call_duration_seconds_count{method="GetById",repository="users"}`) == 0 {
+		t.Fatal("expected the user lookups to be timed")
+	}
+
+	conn := dialSocket(t, user)
+	if metric(t, "chat_websocket_connections") == 0 || metric(t, "chat_websocket_users") == 0 {
+		t.Fatal("expected the socket to be counted")
+	}
+	conn.Close()
+}
+
+func TestRequestIDs(t *testing.T) {
+	t.Parallel()
+
+	req, _ := http.NewRequest(http.MethodGet, testServer.URL+"/healthz", nil)
+	req.Header.Set("X-Request-ID", "upstream-id.42")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if id := res.Header.Get("X-Request-ID"); id != "upstream-id.42" {
+		t.Fatalf("expected the request ID to be propagated, got %q", id)
+	}
+
+	// IDs are generated for requests without one or with one that could forge log entries
+	req.Header.Set("X-Request-ID", `forged" level="error`)
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if id := res.Header.Get("X-Request-ID"); len(id) != 32 {
+		t.Fatalf("expected a generated request ID, got %q", id)
+	}
+}
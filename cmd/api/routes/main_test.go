@@ -0,0 +1,444 @@
+package routes_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Mutay1/chat-backend/cmd/api/controllers"
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
+	"github.com/Mutay1/chat-backend/cmd/api/routes"
+	"github.com/Mutay1/chat-backend/infrastructure/memory"
+	"github.com/Mutay1/chat-backend/infrastructure/metrics"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// testServer serves the whole API over in-memory repositories.
+// It is shared by every test, as the WebSocket hub is a single global manager.
+var testServer *httptest.Server
+
+// testMailer holds the emails sent by the test server.
+var testMailer = memory.NewMailer()
+
+// testAudit holds the audit events recorded by the test server.
+var testAudit = memory.NewAuditController()
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+
+	config := internal.Config{
+		Env:       "development",
+		Version:   "test",
+		JwtSecret: "test-secret",
+	}
+	// short enough for dead sockets to be noticed within a test
+	config.Ws.PingInterval = 2 * time.Second
+	config.Ws.PongWait = 5 * time.Second
+	config.Ws.WriteWait = 5 * time.Second
+	config.Ws.MaxMessageSize = 4096
+	config.AppUrl = "http://app.test"
+
+	repositories := memory.NewRepositories()
+	repositories.Users = metrics.UserRepository{Users: repositories.Users}
+	repositories.Audit = testAudit
+
+	app := internal.Application{
+		Config:       config,
+		Repositories: repositories,
+		Broker:       memory.NewBroker(),
+		Presence:     memory.NewPresence(),
+		Logger:       zap.NewNop(),
+		Mailer:       testMailer,
+	}
+
+	go controllers.Manager.Start(app)
+	testServer = httptest.NewServer(routes.Router(app))
+
+	code := m.Run()
+	testServer.Close()
+	os.Exit(code)
+}
+
+// testUser holds the credentials of a registered user.
+type testUser struct {
+	ID           string
+	Username     string
+	Email        string
+	Password     string
+	Token        string
+	RefreshToken string
+}
+
+// userCounter keeps generated usernames unique across tests sharing the server.
+var userCounter int64
+
+// newTestUser builds unique signup details, without registering them.
+func newTestUser(name string) testUser {
+	n := atomic.AddInt64(&userCounter, 1)
+	username := fmt.Sprintf("%s%d", name, n)
+
+	return testUser{
+		Username: username,
+		Email:    username + "@example.com",
+		Password: "password" + username,
+	}
+}
+
+// signUp registers a new unique user with a verified email and returns their credentials.
+func signUp(t *testing.T, name string) testUser {
+	t.Helper()
+
+	user := signUpUnverified(t, name)
+	res, body := request(t, http.MethodPost, "/users/verify-email", "", gin.H{"token": mailedToken(t, user.Email)})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("verify email: got status %d: %s", res.StatusCode, body)
+	}
+
+	return user
+}
+
+// signUpUnverified registers a new unique user without verifying their email and returns their credentials.
+func signUpUnverified(t *testing.T, name string) testUser {
+	t.Helper()
+
+	user := newTestUser(name)
+	res, body := request(t, http.MethodPost, "/users/signup", "", gin.H{
+		"firstName": "Test",
+		"lastName":  "User",
+		"username":  user.Username,
+		"email":     user.Email,
+		"Password":  user.Password,
+	})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("signup: got status %d: %s", res.StatusCode, body)
+	}
+
+	tokens := struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refreshToken"`
+		UserID       string `json:"userID"`
+	}{}
+	decode(t, body, &tokens)
+
+	user.ID = tokens.UserID
+	user.Token = tokens.Token
+	user.RefreshToken = tokens.RefreshToken
+	return user
+}
+
+// login signs the user in on a new session, returning their credentials with the tokens of that session.
+func login(t *testing.T, user testUser, deviceName string) testUser {
+	t.Helper()
+
+	res, body := request(t, http.MethodPost, "/users/login", "", gin.H{
+		"email":      user.Email,
+		"password":   user.Password,
+		"deviceName": deviceName,
+	})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("login: got status %d: %s", res.StatusCode, body)
+	}
+
+	tokens := struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refreshToken"`
+	}{}
+	decode(t, body, &tokens)
+
+	user.Token = tokens.Token
+	user.RefreshToken = tokens.RefreshToken
+	return user
+}
+
+// mailedTokenPattern extracts the token from the link of a verification email.
+var mailedTokenPattern = regexp.MustCompile(`\?token=([0-9a-f]+)`)
+
+// mailedToken returns the token of the latest email sent to the address.
+func mailedToken(t *testing.T, email string) string {
+	t.Helper()
+
+	sent := testMailer.Sent(email)
+	if len(sent) == 0 {
+		t.Fatalf("no email sent to %s", email)
+	}
+
+	match := mailedTokenPattern.FindStringSubmatch(sent[len(sent)-1].Body)
+	if match == nil {
+		t.Fatalf("no token in email: %s", sent[len(sent)-1].Body)
+	}
+
+	return match[1]
+}
+
+// errorCode returns the code of an error response.
+func errorCode(t *testing.T, body []byte) string {
+	t.Helper()
+
+	failure := struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}{}
+	decode(t, body, &failure)
+
+	return failure.Error.Code
+}
+
+// befriend makes both users accepted friends and returns the friendship ID.
+func befriend(t *testing.T, requester testUser, recipient testUser) string {
+	t.Helper()
+
+	res, body := request(t, http.MethodPost, "/users/request", requester.Token, gin.H{"username": recipient.Username})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("send request: got status %d: %s", res.StatusCode, body)
+	}
+
+	friendship := struct{ ID string }{}
+	decode(t, body, &friendship)
+
+	res, body = request(t, http.MethodPost, "/users/request/accept", recipient.Token, gin.H{"ID": friendship.ID})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("accept request: got status %d: %s", res.StatusCode, body)
+	}
+
+	return friendship.ID
+}
+
+// request sends a JSON request to the test server, authenticated if a token is given.
+func request(t *testing.T, method string, path string, token string, payload interface{}) (*http.Response, []byte) {
+	t.Helper()
+
+	var reqBody io.Reader
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqBody = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, testServer.URL+path, reqBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return send(t, req, token)
+}
+
+// requestForm sends a multipart form request to the test server, authenticated if a token is given.
+func requestForm(t *testing.T, method string, path string, token string, fields map[string]string) (*http.Response, []byte) {
+	t.Helper()
+
+	var reqBody bytes.Buffer
+	writer := multipart.NewWriter(&reqBody)
+	for key, value := range fields {
+		if err := writer.WriteField(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(method, testServer.URL+path, &reqBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	return send(t, req, token)
+}
+
+// send executes the request and reads the whole response body.
+func send(t *testing.T, req *http.Request, token string) (*http.Response, []byte) {
+	t.Helper()
+
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res, resBody
+}
+
+// decode unmarshals a JSON response body, failing the test on error.
+func decode(t *testing.T, body []byte, v interface{}) {
+	t.Helper()
+
+	if err := json.Unmarshal(body, v); err != nil {
+		t.Fatalf("decode %s: %s", body, err)
+	}
+}
+
+// frame is a WebSocket envelope with its payload decoded into a generic map.
+type frame struct {
+	Type    string                 `json:"type"`
+	ID      string                 `json:"id"`
+	Version int                    `json:"version"`
+	Seq     int64                  `json:"seq"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+// dialSocket opens an authenticated WebSocket connection and consumes the welcome frame.
+func dialSocket(t *testing.T, user testUser) *websocket.Conn {
+	t.Helper()
+
+	url := "ws" + strings.TrimPrefix(testServer.URL, "http") + "/ws?token=" + user.Token
+	conn, res, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		status := 0
+		if res != nil {
+			status = res.StatusCode
+		}
+		t.Fatalf("dial socket: got status %d: %s", status, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if welcome := readFrame(t, conn); welcome.Type != "welcome" {
+		t.Fatalf("expected the welcome frame, got %+v", welcome)
+	}
+	return conn
+}
+
+// writeFrame sends a frame of the latest protocol version over the socket.
+func writeFrame(t *testing.T, conn *websocket.Conn, frameType string, id string, payload interface{}) {
+	t.Helper()
+
+	if err := conn.WriteJSON(gin.H{
+		"type":    frameType,
+		"id":      id,
+		"version": 1,
+		"payload": payload,
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// messageCounter keeps generated client message IDs unique across tests.
+var messageCounter int64
+
+// sendMessage sends a message with a new client ID over the socket and waits for its ack or nack,
+// along with the message itself once acknowledged, skipping the frames received in between.
+func sendMessage(t *testing.T, conn *websocket.Conn, payload interface{}) frame {
+	t.Helper()
+
+	id := fmt.Sprintf("client-%d", atomic.AddInt64(&messageCounter, 1))
+	writeFrame(t, conn, "message.send", id, payload)
+
+	// the message is fanned out through the broker, so it may arrive before or after the ack
+	var ack *frame
+	messageIds := map[string]bool{}
+	for {
+		next := readFrame(t, conn)
+		switch {
+		case next.Type == "nack" && next.ID == id:
+			return next
+		case next.Type == "ack" && next.ID == id:
+			ack = &next
+		case next.Type == "message.new":
+			messageIds[next.ID] = true
+		}
+
+		if ack != nil && messageIds[ack.Payload["messageID"].(string)] {
+			return *ack
+		}
+	}
+}
+
+// readFrame reads the next frame from the socket.
+// Presence frames are skipped, as they arrive whenever a friend connects or disconnects.
+func readFrame(t *testing.T, conn *websocket.Conn) frame {
+	t.Helper()
+
+	for {
+		next := readAnyFrame(t, conn)
+		if next.Type != "presence" {
+			return next
+		}
+	}
+}
+
+// readFrameOf reads frames from the socket until the next one of the given type.
+func readFrameOf(t *testing.T, conn *websocket.Conn, frameType string) frame {
+	t.Helper()
+
+	for {
+		next := readAnyFrame(t, conn)
+		if next.Type == frameType {
+			return next
+		}
+	}
+}
+
+// readRevocation reads frames from the socket until its session is revoked, and waits for it to be closed.
+func readRevocation(t *testing.T, conn *websocket.Conn) frame {
+	t.Helper()
+
+	revoked := readFrameOf(t, conn, "session.revoked")
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var err error
+	for err == nil {
+		_, _, err = conn.ReadMessage()
+	}
+	if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Fatalf("expected the socket to close as the session was revoked, got %v", err)
+	}
+
+	return revoked
+}
+
+// readPresence reads frames from the socket until the next presence frame.
+func readPresence(t *testing.T, conn *websocket.Conn) frame {
+	t.Helper()
+
+	return readFrameOf(t, conn, "presence")
+}
+
+// readAnyFrame reads the next frame from the socket, whatever its type.
+func readAnyFrame(t *testing.T, conn *websocket.Conn) frame {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	next := frame{}
+	if err := conn.ReadJSON(&next); err != nil {
+		t.Fatalf("read frame: %s", err)
+	}
+
+	return next
+}
+
+// eventually retries the condition until it holds or the timeout elapses.
+func eventually(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before timeout")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
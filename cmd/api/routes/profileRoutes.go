@@ -2,11 +2,15 @@ package routes
 
 import (
 	controller "github.com/Mutay1/chat-backend/cmd/api/controllers"
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
 	"github.com/gin-gonic/gin"
 )
 
 //ProfileRoutes Function
-func ProfileRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.POST("/users/profile", controller.UpdateProfile())
-	incomingRoutes.GET("/users/profile", controller.GetProfile())
+func ProfileRoutes(app internal.Application, incomingRoutes *gin.RouterGroup) {
+	incomingRoutes.POST("/users/profile", controller.UpdateProfile(app))
+	incomingRoutes.GET("/users/profile", controller.GetProfile(app))
+	incomingRoutes.POST("/users/verify-email/resend", controller.ResendEmailVerification(app))
+	incomingRoutes.POST("/users/password", controller.ChangePassword(app))
+	incomingRoutes.POST("/users/email", controller.ChangeEmail(app))
 }
@@ -0,0 +1,164 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUpdateProfile(t *testing.T) {
+	t.Parallel()
+
+	user := signUp(t, "profile")
+	friend := signUp(t, "profilefriend")
+	befriend(t, user, friend)
+
+	res, body := requestForm(t, http.MethodPost, "/users/profile", user.Token, map[string]string{
+		"status": "Busy",
+		"city":   "Lagos",
+		"about":  "Testing things",
+	})
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("update: expected status %d, got %d: %s", http.StatusCreated, res.StatusCode, body)
+	}
+
+	profile := struct {
+		Status string `json:"status"`
+		City   string `json:"city"`
+		About  string `json:"about"`
+	}{}
+	res, body = request(t, http.MethodGet, "/users/profile", user.Token, nil)
+	decode(t, body, &profile)
+	if res.StatusCode != http.StatusOK || profile.Status != "Busy" || profile.City != "Lagos" || profile.About != "Testing things" {
+		t.Fatalf("get: expected updated profile, got %d: %s", res.StatusCode, body)
+	}
+
+	// the details embedded in friendships follow the profile
+	var friends []struct {
+		Status string `json:"status"`
+	}
+	res, body = request(t, http.MethodGet, "/friends", friend.Token, nil)
+	decode(t, body, &friends)
+	if res.StatusCode != http.StatusOK || len(friends) != 1 || friends[0].Status != "Busy" {
+		t.Fatalf("friends: expected updated status, got %d: %s", res.StatusCode, body)
+	}
+}
+
+// audited reports whether the action was recorded in the audit log of the user.
+func audited(userId string, action string) bool {
+	for _, event := range testAudit.Events(userId) {
+		if event.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+func TestChangePassword(t *testing.T) {
+	t.Parallel()
+
+	user := signUp(t, "changepassword")
+	otherDevice := login(t, user, "Laptop")
+
+	t.Run("wrong current password", func(t *testing.T) {
+		res, body := request(t, http.MethodPost, "/users/password", user.Token, gin.H{
+			"currentPassword": "wrong password",
+			"password":        "new password",
+		})
+		if res.StatusCode != http.StatusForbidden || errorCode(t, body) != "invalid_credentials" {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusForbidden, res.StatusCode, body)
+		}
+	})
+
+	otherConn := dialSocket(t, otherDevice)
+	res, body := request(t, http.MethodPost, "/users/password", user.Token, gin.H{
+		"currentPassword": user.Password,
+		"password":        "new password",
+	})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("change: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+
+	// other sessions are signed out while the current one carries on
+	if revoked := readRevocation(t, otherConn); revoked.ID == "" {
+		t.Fatalf("expected only the other session to be disconnected, got %+v", revoked)
+	}
+	res, body = request(t, http.MethodPost, "/users/refresh-token", "", gin.H{"refreshToken": otherDevice.RefreshToken})
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected the other session to be revoked, got %d: %s", res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodPost, "/users/refresh-token", "", gin.H{"refreshToken": user.RefreshToken})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected the current session to carry on, got %d: %s", res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodPost, "/users/login", "", gin.H{"email": user.Email, "password": "new password"})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("login: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+
+	if !audited(user.ID, "password_changed") {
+		t.Fatalf("expected the change to be audited, got %+v", testAudit.Events(user.ID))
+	}
+}
+
+func TestChangeEmail(t *testing.T) {
+	t.Parallel()
+
+	user := signUp(t, "changeemail")
+	otherDevice := login(t, user, "Laptop")
+	other := signUp(t, "takenemail")
+	newEmail := "changed-" + user.Email
+
+	t.Run("wrong password", func(t *testing.T) {
+		res, body := request(t, http.MethodPost, "/users/email", user.Token, gin.H{"email": newEmail, "password": "wrong password"})
+		if res.StatusCode != http.StatusForbidden {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusForbidden, res.StatusCode, body)
+		}
+	})
+
+	t.Run("taken email", func(t *testing.T) {
+		res, body := request(t, http.MethodPost, "/users/email", user.Token, gin.H{"email": other.Email, "password": user.Password})
+		if res.StatusCode != http.StatusConflict {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusConflict, res.StatusCode, body)
+		}
+	})
+
+	res, body := request(t, http.MethodPost, "/users/email", user.Token, gin.H{"email": newEmail, "password": user.Password})
+	if res.StatusCode != http.StatusAccepted {
+		t.Fatalf("change: expected status %d, got %d: %s", http.StatusAccepted, res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodGet, "/users/profile", otherDevice.Token, nil)
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected the other session to be revoked, got %d: %s", res.StatusCode, body)
+	}
+
+	// verification emails are throttled like resends
+	res, body = request(t, http.MethodPost, "/users/email", user.Token, gin.H{"email": "again-" + newEmail, "password": user.Password})
+	if res.StatusCode != http.StatusTooManyRequests || res.Header.Get("Retry-After") == "" {
+		t.Fatalf("repeated change: expected status %d, got %d: %s", http.StatusTooManyRequests, res.StatusCode, body)
+	}
+
+	// the email only changes once the new one is verified
+	res, body = request(t, http.MethodPost, "/users/login", "", gin.H{"email": newEmail, "password": user.Password})
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("login before verification: expected status %d, got %d: %s", http.StatusUnauthorized, res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodPost, "/users/verify-email", "", gin.H{"token": mailedToken(t, newEmail)})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("verify: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodPost, "/users/login", "", gin.H{"email": newEmail, "password": user.Password})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("login after verification: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+
+	if !audited(user.ID, "email_change_requested") || !audited(user.ID, "email_changed") {
+		t.Fatalf("expected the change to be audited, got %+v", testAudit.Events(user.ID))
+	}
+}
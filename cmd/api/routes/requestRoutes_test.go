@@ -0,0 +1,132 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFriendRequestLifecycle(t *testing.T) {
+	t.Parallel()
+
+	requester := signUp(t, "requester")
+	recipient := signUp(t, "recipient")
+
+	res, body := request(t, http.MethodPost, "/users/request", requester.Token, gin.H{"username": recipient.Username})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("send: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+	sentRequest := struct{ ID string }{}
+	decode(t, body, &sentRequest)
+
+	// both directions count as the same request
+	res, body = request(t, http.MethodPost, "/users/request", recipient.Token, gin.H{"username": requester.Username})
+	if res.StatusCode != http.StatusConflict {
+		t.Fatalf("duplicate: expected status %d, got %d: %s", http.StatusConflict, res.StatusCode, body)
+	}
+
+	var pending []struct{ ID string }
+	res, body = request(t, http.MethodGet, "/users/request/sent", requester.Token, nil)
+	decode(t, body, &pending)
+	if res.StatusCode != http.StatusOK || len(pending) != 1 || pending[0].ID != sentRequest.ID {
+		t.Fatalf("sent: expected the request, got %d: %s", res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodGet, "/users/request/received", recipient.Token, nil)
+	decode(t, body, &pending)
+	if res.StatusCode != http.StatusOK || len(pending) != 1 || pending[0].ID != sentRequest.ID {
+		t.Fatalf("received: expected the request, got %d: %s", res.StatusCode, body)
+	}
+
+	// only the recipient can accept
+	res, body = request(t, http.MethodPost, "/users/request/accept", requester.Token, gin.H{"ID": sentRequest.ID})
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("accept by requester: expected status %d, got %d: %s", http.StatusNotFound, res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodPost, "/users/request/accept", recipient.Token, gin.H{"ID": sentRequest.ID})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("accept: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+
+	var friends []struct {
+		Username string `json:"username"`
+	}
+	res, body = request(t, http.MethodGet, "/friends", requester.Token, nil)
+	decode(t, body, &friends)
+	if res.StatusCode != http.StatusOK || len(friends) != 1 || friends[0].Username != recipient.Username {
+		t.Fatalf("friends: expected the recipient, got %d: %s", res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodPost, "/users/request/delete", recipient.Token, gin.H{"ID": sentRequest.ID})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("delete: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodGet, "/friends", requester.Token, nil)
+	decode(t, body, &friends)
+	if res.StatusCode != http.StatusOK || len(friends) != 0 {
+		t.Fatalf("friends after delete: expected none, got %d: %s", res.StatusCode, body)
+	}
+}
+
+func TestSendRequestErrors(t *testing.T) {
+	t.Parallel()
+
+	user := signUp(t, "lonely")
+
+	res, body := request(t, http.MethodPost, "/users/request", user.Token, gin.H{"username": user.Username})
+	if res.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("self request: expected status %d, got %d: %s", http.StatusUnprocessableEntity, res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodPost, "/users/request", user.Token, gin.H{"username": "nobody-by-this-name"})
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown user: expected status %d, got %d: %s", http.StatusNotFound, res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodPost, "/users/request", user.Token, gin.H{})
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("missing username: expected status %d, got %d: %s", http.StatusBadRequest, res.StatusCode, body)
+	}
+}
+
+func TestDeclineFriendRequest(t *testing.T) {
+	t.Parallel()
+
+	requester := signUp(t, "decliner")
+	recipient := signUp(t, "declined")
+	stranger := signUp(t, "bystander")
+
+	res, body := request(t, http.MethodPost, "/users/request", requester.Token, gin.H{"username": recipient.Username})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("send: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+	sentRequest := struct{ ID string }{}
+	decode(t, body, &sentRequest)
+
+	// only the members of a request can decline it
+	res, body = request(t, http.MethodPost, "/users/request/delete", stranger.Token, gin.H{"ID": sentRequest.ID})
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("decline by stranger: expected status %d, got %d: %s", http.StatusNotFound, res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodPost, "/users/request/delete", recipient.Token, gin.H{"ID": sentRequest.ID})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("decline: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+
+	var pending []struct{ ID string }
+	res, body = request(t, http.MethodGet, "/users/request/received", recipient.Token, nil)
+	decode(t, body, &pending)
+	if res.StatusCode != http.StatusOK || len(pending) != 0 {
+		t.Fatalf("received: expected no requests, got %d: %s", res.StatusCode, body)
+	}
+
+	// a declined request no longer pairs both users, so it can be sent again
+	res, body = request(t, http.MethodPost, "/users/request", requester.Token, gin.H{"username": recipient.Username})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("resend: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+}
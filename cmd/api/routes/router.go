@@ -1,48 +1,59 @@
 package routes
 
 import (
-	"fmt"
 	"github.com/Mutay1/chat-backend/cmd/api/internal"
 	"github.com/Mutay1/chat-backend/cmd/api/middleware"
+	helper "github.com/Mutay1/chat-backend/helpers"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"net/http"
 	"time"
 )
 
 func Router(app internal.Application) *gin.Engine {
 	gin.EnableJsonDecoderDisallowUnknownFields()
-	router := gin.Default()
+	router := gin.New()
+	router.Use(middleware.RequestID(app), middleware.Logger(), gin.Recovery(), middleware.Metrics())
 
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"PUT", "PATCH", "GET", "POST", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"PUT", "PATCH", "GET", "POST", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type", middleware.RequestIDHeader},
+		ExposeHeaders:    []string{"Content-Length", middleware.RequestIDHeader},
 		AllowCredentials: true,
 		AllowOriginFunc: func(origin string) bool {
 			return origin == "https://github.com"
 		},
 		MaxAge: 12 * time.Hour,
 	}))
+
+	HealthRoutes(app, router)
 	UserRoutes(app, router)
-	WsRoutes(router)
+	WsRoutes(app, router)
 
-	router.Use(middleware.Authentication(app))
-	ProfileRoutes(router)
-	RequestRoutes(router)
-	FriendRoutes(router)
+	// unmatched routes are answered by the engine, so authentication is kept to the routes needing it
+	authenticated := router.Group("", middleware.Authentication(app))
+	ProfileRoutes(app, authenticated)
+	SessionRoutes(app, authenticated)
+	RequestRoutes(app, authenticated)
+	FriendRoutes(app, authenticated)
+	ConversationRoutes(app, authenticated)
+	GroupRoutes(app, authenticated)
 
 	// API-1
-	router.GET("/api-1", func(c *gin.Context) {
-		fmt.Println(c.Get("email"))
+	authenticated.GET("/api-1", func(c *gin.Context) {
 		c.JSON(200, gin.H{"success": "Access granted for api-1"})
 
 	})
 
 	// API-2
-	router.GET("/api-2", func(c *gin.Context) {
+	authenticated.GET("/api-2", func(c *gin.Context) {
 		c.JSON(200, gin.H{"success": "Access granted for api-2"})
 	})
 
+	router.NoRoute(func(ctx *gin.Context) {
+		helper.AbortWithError(ctx, http.StatusNotFound, helper.ErrorNotFound, "route not found")
+	})
+
 	return router
 }
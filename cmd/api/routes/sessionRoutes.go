@@ -0,0 +1,15 @@
+package routes
+
+import (
+	controller "github.com/Mutay1/chat-backend/cmd/api/controllers"
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
+	"github.com/gin-gonic/gin"
+)
+
+// SessionRoutes function
+func SessionRoutes(app internal.Application, incomingRoutes *gin.RouterGroup) {
+	incomingRoutes.GET("/users/sessions", controller.GetSessions(app))
+	incomingRoutes.DELETE("/users/sessions/:id", controller.RevokeSession(app))
+	incomingRoutes.POST("/users/logout", controller.Logout(app))
+	incomingRoutes.POST("/users/logout-everywhere", controller.LogoutEverywhere(app))
+}
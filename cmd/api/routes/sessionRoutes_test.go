@@ -0,0 +1,142 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSessions(t *testing.T) {
+	t.Parallel()
+
+	phone := signUp(t, "sessions")
+	laptop := login(t, phone, "Laptop")
+	tablet := login(t, phone, "Tablet")
+
+	// signing in on a device leaves the others signed in
+	for _, device := range []testUser{phone, laptop, tablet} {
+		res, body := request(t, http.MethodPost, "/users/refresh-token", "", gin.H{"refreshToken": device.RefreshToken})
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("refresh: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+		}
+	}
+
+	res, body := request(t, http.MethodGet, "/users/sessions", laptop.Token, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("list: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+
+	sessions := []struct {
+		ID         string `json:"id"`
+		DeviceName string `json:"deviceName"`
+		Current    bool   `json:"current"`
+	}{}
+	decode(t, body, &sessions)
+	if len(sessions) != 3 {
+		t.Fatalf("list: expected 3 sessions, got %s", body)
+	}
+
+	var tabletId string
+	for _, session := range sessions {
+		if session.Current != (session.DeviceName == "Laptop") {
+			t.Fatalf("list: expected the laptop session to be current, got %s", body)
+		}
+		if session.DeviceName == "Tablet" {
+			tabletId = session.ID
+		}
+	}
+
+	t.Run("unknown session", func(t *testing.T) {
+		res, body := request(t, http.MethodDelete, "/users/sessions/"+tabletId, signUp(t, "stranger").Token, nil)
+		if res.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNotFound, res.StatusCode, body)
+		}
+	})
+
+	res, body = request(t, http.MethodDelete, "/users/sessions/"+tabletId, laptop.Token, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("revoke: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodGet, "/users/profile", tablet.Token, nil)
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected the revoked session to be signed out, got %d: %s", res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodPost, "/users/logout", laptop.Token, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("logout: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodGet, "/users/profile", laptop.Token, nil)
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected the logged out session to be revoked, got %d: %s", res.StatusCode, body)
+	}
+
+	laptop = login(t, phone, "Laptop")
+	res, body = request(t, http.MethodPost, "/users/logout-everywhere", laptop.Token, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("logout everywhere: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+
+	for _, device := range []testUser{phone, laptop} {
+		res, body := request(t, http.MethodGet, "/users/profile", device.Token, nil)
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected every session to be signed out, got %d: %s", res.StatusCode, body)
+		}
+	}
+}
+
+func TestRefreshTokenIsNotAccessToken(t *testing.T) {
+	t.Parallel()
+
+	user := signUp(t, "tokentypes")
+
+	res, body := request(t, http.MethodGet, "/users/profile", user.RefreshToken, nil)
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected refresh tokens to be refused as access tokens, got %d: %s", res.StatusCode, body)
+	}
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	t.Parallel()
+
+	user := signUp(t, "tokenreuse")
+	conn := dialSocket(t, user)
+
+	res, body := request(t, http.MethodPost, "/users/refresh-token", "", gin.H{"refreshToken": user.RefreshToken})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("refresh: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+	rotated := struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refreshToken"`
+	}{}
+	decode(t, body, &rotated)
+	if rotated.RefreshToken == user.RefreshToken {
+		t.Fatalf("expected the refresh token to be rotated")
+	}
+
+	res, body = request(t, http.MethodPost, "/users/refresh-token", "", gin.H{"refreshToken": user.RefreshToken})
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("reuse: expected status %d, got %d: %s", http.StatusUnauthorized, res.StatusCode, body)
+	}
+	if !audited(user.ID, "refresh_token_reused") {
+		t.Fatalf("expected the reuse to be audited")
+	}
+
+	// the whole session is revoked, including the token it was rotated to
+	res, body = request(t, http.MethodPost, "/users/refresh-token", "", gin.H{"refreshToken": rotated.RefreshToken})
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("rotated: expected status %d, got %d: %s", http.StatusUnauthorized, res.StatusCode, body)
+	}
+	res, body = request(t, http.MethodGet, "/users/profile", rotated.Token, nil)
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected the session to be signed out, got %d: %s", res.StatusCode, body)
+	}
+
+	if revoked := readRevocation(t, conn); revoked.ID != "" {
+		t.Fatalf("expected every session to be disconnected, got %+v", revoked)
+	}
+}
@@ -11,4 +11,7 @@ func UserRoutes(app internal.Application, incomingRoutes *gin.Engine) {
 	incomingRoutes.POST("/users/signup", controller.SignUp(app))
 	incomingRoutes.POST("/users/login", controller.Login(app))
 	incomingRoutes.POST("/users/refresh-token", controller.RefreshToken(app))
+	incomingRoutes.POST("/users/verify-email", controller.VerifyEmail(app))
+	incomingRoutes.POST("/users/password/forgot", controller.ForgotPassword(app))
+	incomingRoutes.POST("/users/password/reset", controller.ResetPassword(app))
 }
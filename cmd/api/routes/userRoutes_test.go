@@ -0,0 +1,331 @@
+package routes_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
+	"github.com/Mutay1/chat-backend/cmd/api/routes"
+	"github.com/Mutay1/chat-backend/infrastructure/memory"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestSignUp(t *testing.T) {
+	t.Parallel()
+
+	user := signUp(t, "signup")
+	if user.ID == "" || user.Token == "" || user.RefreshToken == "" {
+		t.Fatalf("expected user ID and tokens, got %+v", user)
+	}
+
+	t.Run("duplicate details", func(t *testing.T) {
+		res, body := request(t, http.MethodPost, "/users/signup", "", gin.H{
+			"firstName": "Test",
+			"lastName":  "User",
+			"username":  user.Username,
+			"email":     user.Email,
+			"Password":  user.Password,
+		})
+		if res.StatusCode != http.StatusConflict {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusConflict, res.StatusCode, body)
+		}
+	})
+
+	t.Run("invalid details", func(t *testing.T) {
+		details := newTestUser("invalid")
+		res, body := request(t, http.MethodPost, "/users/signup", "", gin.H{
+			"firstName": "Test",
+			"lastName":  "User",
+			"username":  details.Username,
+			"email":     "not-an-email",
+			"Password":  details.Password,
+		})
+		if res.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, res.StatusCode, body)
+		}
+
+		// every invalid field is detailed
+		failure := struct {
+			Error struct {
+				Code    string            `json:"code"`
+				Details map[string]string `json:"details"`
+			} `json:"error"`
+		}{}
+		decode(t, body, &failure)
+		if failure.Error.Code != "validation_failed" || failure.Error.Details["email"] == "" {
+			t.Fatalf("expected the email to be reported invalid, got %s", body)
+		}
+	})
+}
+
+func TestInMemoryRepositories(t *testing.T) {
+	t.Parallel()
+
+	// a second API over its own in-memory repositories shares nothing with the test server
+	router := routes.Router(internal.Application{
+		Config:       internal.Config{JwtSecret: "test-secret"},
+		Repositories: memory.NewRepositories(),
+		Broker:       memory.NewBroker(),
+		Presence:     memory.NewPresence(),
+		Logger:       zap.NewNop(),
+		Mailer:       memory.NewMailer(),
+	})
+	signUpLocally := func(details testUser) int {
+		t.Helper()
+
+		encoded, err := json.Marshal(gin.H{
+			"firstName": "Test",
+			"lastName":  "User",
+			"username":  details.Username,
+			"email":     details.Email,
+			"Password":  details.Password,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/users/signup", bytes.NewReader(encoded))
+		req.Header.Set("Content-Type", "application/json")
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	user := signUp(t, "shared")
+	if status := signUpLocally(user); status != http.StatusOK {
+		t.Fatalf("signup elsewhere: expected status %d, got %d", http.StatusOK, status)
+	}
+
+	// duplicates are detected like they are by the database
+	duplicate := newTestUser("duplicate")
+	duplicate.Email = user.Email
+	if status := signUpLocally(duplicate); status != http.StatusConflict {
+		t.Fatalf("duplicate email: expected status %d, got %d", http.StatusConflict, status)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	t.Parallel()
+
+	user := signUp(t, "login")
+
+	res, body := request(t, http.MethodPost, "/users/login", "", gin.H{
+		"email":    user.Email,
+		"Password": user.Password,
+	})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+
+	tokens := struct {
+		Token  string `json:"token"`
+		UserID string `json:"userID"`
+	}{}
+	decode(t, body, &tokens)
+	if tokens.UserID != user.ID || tokens.Token == "" {
+		t.Fatalf("expected token for user %s, got %+v", user.ID, tokens)
+	}
+
+	t.Run("wrong password", func(t *testing.T) {
+		res, body := request(t, http.MethodPost, "/users/login", "", gin.H{
+			"email":    user.Email,
+			"Password": "not-the-password",
+		})
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusUnauthorized, res.StatusCode, body)
+		}
+	})
+
+	t.Run("unknown email", func(t *testing.T) {
+		res, body := request(t, http.MethodPost, "/users/login", "", gin.H{
+			"email":    "unknown@example.com",
+			"Password": user.Password,
+		})
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusUnauthorized, res.StatusCode, body)
+		}
+	})
+}
+
+func TestRefreshToken(t *testing.T) {
+	t.Parallel()
+
+	user := signUp(t, "refresh")
+
+	res, body := request(t, http.MethodPost, "/users/refresh-token", "", gin.H{
+		"refreshToken": user.RefreshToken,
+	})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+
+	tokens := struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refreshToken"`
+	}{}
+	decode(t, body, &tokens)
+	if tokens.Token == "" || tokens.RefreshToken == "" {
+		t.Fatalf("expected new tokens, got %+v", tokens)
+	}
+
+	t.Run("bad refresh token", func(t *testing.T) {
+		res, body := request(t, http.MethodPost, "/users/refresh-token", "", gin.H{
+			"refreshToken": "not-a-refresh-token",
+		})
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusUnauthorized, res.StatusCode, body)
+		}
+	})
+
+	t.Run("token issued before sessions", func(t *testing.T) {
+		// such tokens named nobody, so they can't be tied to the user they were issued to
+		legacyToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte("test-secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, body := request(t, http.MethodPost, "/users/refresh-token", "", gin.H{
+			"refreshToken": legacyToken,
+		})
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusUnauthorized, res.StatusCode, body)
+		}
+	})
+}
+
+func TestAuthentication(t *testing.T) {
+	t.Parallel()
+
+	res, body := request(t, http.MethodGet, "/users/profile", "", nil)
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected status %d without token, got %d: %s", http.StatusUnauthorized, res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodGet, "/users/profile", "not-a-token", nil)
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected status %d with invalid token, got %d: %s", http.StatusUnauthorized, res.StatusCode, body)
+	}
+
+	// unknown routes are not found whether authenticated or not
+	res, body = request(t, http.MethodGet, "/nowhere", "", nil)
+	if res.StatusCode != http.StatusNotFound || errorCode(t, body) != "not_found" {
+		t.Fatalf("expected status %d for an unknown route, got %d: %s", http.StatusNotFound, res.StatusCode, body)
+	}
+}
+
+func TestEmailVerification(t *testing.T) {
+	t.Parallel()
+
+	user := signUpUnverified(t, "unverified")
+	recipient := signUp(t, "recipient")
+
+	res, body := request(t, http.MethodPost, "/users/request", user.Token, gin.H{"username": recipient.Username})
+	if res.StatusCode != http.StatusForbidden || errorCode(t, body) != "email_not_verified" {
+		t.Fatalf("expected unverified users to be blocked from sending requests, got %d: %s", res.StatusCode, body)
+	}
+
+	t.Run("resend rate limited", func(t *testing.T) {
+		res, body := request(t, http.MethodPost, "/users/verify-email/resend", user.Token, nil)
+		if res.StatusCode != http.StatusTooManyRequests || res.Header.Get("Retry-After") == "" {
+			t.Fatalf("expected status %d with Retry-After, got %d: %s", http.StatusTooManyRequests, res.StatusCode, body)
+		}
+	})
+
+	t.Run("bad token", func(t *testing.T) {
+		res, body := request(t, http.MethodPost, "/users/verify-email", "", gin.H{"token": "not-a-token"})
+		if res.StatusCode != http.StatusBadRequest || errorCode(t, body) != "invalid_token" {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, res.StatusCode, body)
+		}
+	})
+
+	token := mailedToken(t, user.Email)
+	res, body = request(t, http.MethodPost, "/users/verify-email", "", gin.H{"token": token})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("verify: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodPost, "/users/verify-email", "", gin.H{"token": token})
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("reused token: expected status %d, got %d: %s", http.StatusBadRequest, res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodPost, "/users/request", user.Token, gin.H{"username": recipient.Username})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected verified users to send requests, got %d: %s", res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodPost, "/users/verify-email/resend", user.Token, nil)
+	if res.StatusCode != http.StatusConflict {
+		t.Fatalf("resend once verified: expected status %d, got %d: %s", http.StatusConflict, res.StatusCode, body)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	t.Parallel()
+
+	user := signUp(t, "forgetful")
+
+	// unknown emails are answered like registered ones
+	res, unknownBody := request(t, http.MethodPost, "/users/password/forgot", "", gin.H{"email": "nobody@example.com"})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("forgot unknown email: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, unknownBody)
+	}
+
+	res, body := request(t, http.MethodPost, "/users/password/forgot", "", gin.H{"email": user.Email})
+	if res.StatusCode != http.StatusOK || string(body) != string(unknownBody) {
+		t.Fatalf("forgot: expected the unknown email response, got %d: %s", res.StatusCode, body)
+	}
+
+	// the verification email was the first one sent
+	eventually(t, func() bool { return len(testMailer.Sent(user.Email)) == 2 })
+	token := mailedToken(t, user.Email)
+
+	t.Run("short password", func(t *testing.T) {
+		res, body := request(t, http.MethodPost, "/users/password/reset", "", gin.H{"token": token, "password": "short"})
+		if res.StatusCode != http.StatusBadRequest || errorCode(t, body) != "validation_failed" {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, res.StatusCode, body)
+		}
+	})
+
+	conn := dialSocket(t, user)
+	res, body = request(t, http.MethodPost, "/users/password/reset", "", gin.H{"token": token, "password": "new password"})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("reset: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+	if revoked := readRevocation(t, conn); revoked.ID != "" {
+		t.Fatalf("expected every session to be disconnected, got %+v", revoked)
+	}
+
+	res, body = request(t, http.MethodPost, "/users/password/reset", "", gin.H{"token": token, "password": "another password"})
+	if res.StatusCode != http.StatusBadRequest || errorCode(t, body) != "invalid_token" {
+		t.Fatalf("reused token: expected status %d, got %d: %s", http.StatusBadRequest, res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodPost, "/users/refresh-token", "", gin.H{"refreshToken": user.RefreshToken})
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected the refresh token to be revoked, got %d: %s", res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodGet, "/users/profile", user.Token, nil)
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected the access token to be revoked, got %d: %s", res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodPost, "/users/login", "", gin.H{"email": user.Email, "password": user.Password})
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("old password: expected status %d, got %d: %s", http.StatusUnauthorized, res.StatusCode, body)
+	}
+
+	res, body = request(t, http.MethodPost, "/users/login", "", gin.H{"email": user.Email, "password": "new password"})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("new password: expected status %d, got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+}
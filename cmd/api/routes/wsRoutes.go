@@ -2,11 +2,13 @@ package routes
 
 import (
 	controller "github.com/Mutay1/chat-backend/cmd/api/controllers"
+	"github.com/Mutay1/chat-backend/cmd/api/internal"
 	"github.com/gin-gonic/gin"
 )
 
-//UserRoutes function
-func WsRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/ws", controller.WsHandler)
+// WsRoutes function
+// The WebSocket route authenticates on its own, as browsers cannot set headers on upgrade requests.
+func WsRoutes(app internal.Application, incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/ws", controller.WsHandler(app))
 	incomingRoutes.GET("/pong", controller.Pong())
 }
@@ -0,0 +1,566 @@
+package routes_test
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	helper "github.com/Mutay1/chat-backend/helpers"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocketMessageRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	sender := signUp(t, "sender")
+	recipient := signUp(t, "receiver")
+	befriend(t, sender, recipient)
+
+	senderConn := dialSocket(t, sender)
+	recipientConn := dialSocket(t, recipient)
+
+	writeFrame(t, senderConn, "message.send", "hello-1", map[string]interface{}{
+		"recipientID": recipient.ID,
+		"content":     "hello there",
+	})
+
+	// both sides receive the message, and the sender is told it was stored
+	frames := map[string]frame{"recipient": readFrame(t, recipientConn)}
+	for i := 0; i < 2; i++ {
+		next := readFrame(t, senderConn)
+		frames[next.Type] = next
+	}
+
+	for _, frame := range []frame{frames["recipient"], frames["message.new"]} {
+		if frame.Type != "message.new" || frame.Payload["content"] != "hello there" || frame.Payload["sender"] != sender.ID {
+			t.Fatalf("expected the sent message, got %+v", frame)
+		}
+		if frame.Version != 1 || frame.ID == "" || frame.ID != frame.Payload["id"] {
+			t.Fatalf("expected a version 1 frame identified by the message, got %+v", frame)
+		}
+	}
+
+	ack := frames["ack"]
+	if ack.ID != "hello-1" || ack.Payload["messageID"] != frames["recipient"].ID || ack.Payload["createdAt"] == nil {
+		t.Fatalf("expected the message to be acknowledged, got %+v", ack)
+	}
+
+	// resending it is acknowledged again without duplicating it
+	writeFrame(t, senderConn, "message.send", "hello-1", map[string]interface{}{
+		"recipientID": recipient.ID,
+		"content":     "hello there",
+	})
+	if again := readFrame(t, senderConn); again.Type != "ack" || again.Payload["messageID"] != ack.Payload["messageID"] {
+		t.Fatalf("expected the original message to be acknowledged, got %+v", again)
+	}
+
+	page := struct {
+		Messages []struct {
+			Content string `json:"content"`
+		} `json:"messages"`
+	}{}
+	res, body := request(t, http.MethodGet, "/conversations/"+recipient.ID+"/messages", sender.Token, nil)
+	decode(t, body, &page)
+	if res.StatusCode != http.StatusOK || len(page.Messages) != 1 || page.Messages[0].Content != "hello there" {
+		t.Fatalf("history: expected the message to be stored once, got %d: %s", res.StatusCode, body)
+	}
+}
+
+func TestWebSocketReceipts(t *testing.T) {
+	t.Parallel()
+
+	sender := signUp(t, "receiptsender")
+	recipient := signUp(t, "receiptreader")
+	befriend(t, sender, recipient)
+
+	senderConn := dialSocket(t, sender)
+	recipientConn := dialSocket(t, recipient)
+
+	var ids []string
+	for _, content := range []string{"first", "second"} {
+		sendMessage(t, senderConn, map[string]interface{}{
+			"recipientID": recipient.ID,
+			"content":     content,
+		})
+		ids = append(ids, readFrame(t, recipientConn).ID)
+	}
+
+	receipt := func(status string, id string) {
+		t.Helper()
+		writeFrame(t, recipientConn, "receipt.update", "", map[string]interface{}{
+			"recipientID": sender.ID,
+			"messageID":   id,
+			"status":      status,
+		})
+
+		// the sender learns how far the recipient has got
+		frame := readFrame(t, senderConn)
+		if frame.Type != "receipt" || frame.Payload["status"] != status || frame.Payload["messageID"] != id || frame.Payload["userID"] != recipient.ID {
+			t.Fatalf("expected a %s receipt for %s, got %+v", status, id, frame)
+		}
+		readFrame(t, recipientConn)
+	}
+
+	receipt("delivered", ids[1])
+	receipt("read", ids[0])
+	// cursors never move backwards
+	receipt("delivered", ids[0])
+
+	rejected := []struct {
+		name      string
+		conn      *websocket.Conn
+		recipient string
+		id        string
+	}{
+		{name: "future message", conn: recipientConn, recipient: sender.ID, id: "ffffffffffffffffffffffff"},
+		{name: "own message", conn: senderConn, recipient: recipient.ID, id: ids[1]},
+	}
+	for _, test := range rejected {
+		writeFrame(t, test.conn, "receipt.update", test.name, map[string]interface{}{
+			"recipientID": test.recipient,
+			"messageID":   test.id,
+			"status":      "read",
+		})
+		if frame := readFrame(t, test.conn); frame.Payload["code"] != "message_not_found" {
+			t.Fatalf("%s: expected a message_not_found error, got %+v", test.name, frame)
+		}
+	}
+
+	page := struct {
+		Receipts []struct {
+			UserID      string `json:"userID"`
+			DeliveredID string `json:"deliveredID"`
+			ReadID      string `json:"readID"`
+		} `json:"receipts"`
+	}{}
+	res, body := request(t, http.MethodGet, "/conversations/"+recipient.ID+"/messages", sender.Token, nil)
+	decode(t, body, &page)
+	if res.StatusCode != http.StatusOK || len(page.Receipts) != 1 {
+		t.Fatalf("history: expected a single receipt, got %d: %s", res.StatusCode, body)
+	}
+	if got := page.Receipts[0]; got.UserID != recipient.ID || got.DeliveredID != ids[1] || got.ReadID != ids[0] {
+		t.Fatalf("expected the recipient to have received %s and read %s, got %+v", ids[1], ids[0], got)
+	}
+
+	var friends []struct {
+		UnreadCount int `json:"unreadCount"`
+	}
+	_, body = request(t, http.MethodGet, "/friends", recipient.Token, nil)
+	decode(t, body, &friends)
+	if len(friends) != 1 || friends[0].UnreadCount != 1 {
+		t.Fatalf("expected a single unread message, got %s", body)
+	}
+}
+
+func TestWebSocketInvalidFrames(t *testing.T) {
+	t.Parallel()
+
+	sender := signUp(t, "invalid")
+	friend := signUp(t, "invalidfriend")
+	stranger := signUp(t, "invalidstranger")
+	befriend(t, sender, friend)
+
+	conn := dialSocket(t, sender)
+
+	tests := []struct {
+		name      string
+		frame     interface{}
+		frameType string
+		code      string
+		frameID   string
+	}{
+		{
+			name:      "malformed",
+			frame:     "not an envelope",
+			frameType: "error",
+			code:      "invalid_frame",
+		},
+		{
+			name:      "unsupported version",
+			frame:     map[string]interface{}{"type": "receipt.update", "id": "v2", "version": 2, "payload": map[string]interface{}{}},
+			frameType: "error",
+			code:      "unsupported_version",
+			frameID:   "v2",
+		},
+		{
+			name:      "unknown type",
+			frame:     map[string]interface{}{"type": "info", "id": "legacy", "version": 1},
+			frameType: "error",
+			code:      "unknown_type",
+			frameID:   "legacy",
+		},
+		{
+			name: "missing message id",
+			frame: map[string]interface{}{"type": "message.send", "version": 1, "payload": map[string]interface{}{
+				"recipientID": friend.ID,
+				"content":     "anonymous",
+			}},
+			frameType: "error",
+			code:      "invalid_frame",
+		},
+		{
+			name: "forged sender",
+			frame: map[string]interface{}{"type": "message.send", "id": "forged", "version": 1, "payload": map[string]interface{}{
+				"sender":      friend.ID,
+				"recipientID": sender.ID,
+				"content":     "not from me",
+			}},
+			frameType: "nack",
+			code:      "invalid_payload",
+			frameID:   "forged",
+		},
+		{
+			name: "missing recipient",
+			frame: map[string]interface{}{"type": "message.send", "id": "nowhere", "version": 1, "payload": map[string]interface{}{
+				"content": "to nobody",
+			}},
+			frameType: "nack",
+			code:      "invalid_payload",
+			frameID:   "nowhere",
+		},
+		{
+			name: "stranger",
+			frame: map[string]interface{}{"type": "message.send", "id": "stranger", "version": 1, "payload": map[string]interface{}{
+				"recipientID": stranger.ID,
+				"content":     "who are you",
+			}},
+			frameType: "nack",
+			code:      "conversation_not_found",
+			frameID:   "stranger",
+		},
+	}
+
+	for _, test := range tests {
+		if err := conn.WriteJSON(test.frame); err != nil {
+			t.Fatal(err)
+		}
+
+		frame := readFrame(t, conn)
+		if frame.Type != test.frameType || frame.Payload["code"] != test.code || frame.ID != test.frameID {
+			t.Fatalf("%s: expected a %s %s for frame %q, got %+v", test.name, test.code, test.frameType, test.frameID, frame)
+		}
+	}
+}
+
+func TestWebSocketAuthentication(t *testing.T) {
+	t.Parallel()
+
+	url := "ws" + strings.TrimPrefix(testServer.URL, "http") + "/ws"
+
+	_, res, err := websocket.DefaultDialer.Dial(url+"?token=not-a-token", nil)
+	if err == nil || res == nil || res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected status %d for an invalid token, got %v", http.StatusUnauthorized, err)
+	}
+
+	t.Run("token as first frame", func(t *testing.T) {
+		user := signUp(t, "framed")
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		writeFrame(t, conn, "auth", "", map[string]string{"token": user.Token})
+		if frame := readFrame(t, conn); frame.Type != "welcome" || frame.Payload["userID"] != user.ID {
+			t.Fatalf("expected the welcome frame, got %+v", frame)
+		}
+	})
+
+	t.Run("token as subprotocol", func(t *testing.T) {
+		user := signUp(t, "protocol")
+		dialer := websocket.Dialer{Subprotocols: []string{"access_token", user.Token}}
+		conn, _, err := dialer.Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		if conn.Subprotocol() != "access_token" {
+			t.Fatalf("expected the access_token subprotocol, got %q", conn.Subprotocol())
+		}
+		if frame := readFrame(t, conn); frame.Type != "welcome" {
+			t.Fatalf("expected the welcome frame, got %+v", frame)
+		}
+	})
+}
+
+func TestWebSocketIdentity(t *testing.T) {
+	t.Parallel()
+
+	url := "ws" + strings.TrimPrefix(testServer.URL, "http") + "/ws"
+
+	t.Run("user from the token", func(t *testing.T) {
+		user := signUp(t, "genuine")
+		victim := signUp(t, "impersonated")
+
+		// the uid parameter sockets used to trust is ignored
+		conn, _, err := websocket.DefaultDialer.Dial(url+"?uid="+victim.ID+"&token="+user.Token, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		if frame := readFrame(t, conn); frame.Type != "welcome" || frame.Payload["userID"] != user.ID {
+			t.Fatalf("expected to be welcomed as %s, got %+v", user.ID, frame)
+		}
+	})
+
+	t.Run("expiring tokens", func(t *testing.T) {
+		user := signUp(t, "expiring")
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, helper.Claims{
+			Type: "access",
+			StandardClaims: jwt.StandardClaims{
+				Subject:   user.ID,
+				ExpiresAt: time.Now().Add(2 * time.Second).Unix(),
+			},
+		}).SignedString([]byte("test-secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		conn, _, err := websocket.DefaultDialer.Dial(url+"?token="+token, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if frame := readFrame(t, conn); frame.Type != "welcome" {
+			t.Fatalf("expected the welcome frame, got %+v", frame)
+		}
+
+		// the socket is closed once the token expires, well before this deadline
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for err == nil {
+			_, _, err = conn.ReadMessage()
+		}
+		if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+			t.Fatalf("expected the socket to close as the token expired, got %v", err)
+		}
+	})
+}
+
+func TestWebSocketVersionNegotiation(t *testing.T) {
+	t.Parallel()
+
+	user := signUp(t, "negotiator")
+	url := "ws" + strings.TrimPrefix(testServer.URL, "http") + "/ws?token=" + user.Token
+
+	dialer := websocket.Dialer{Subprotocols: []string{"chat.v1"}}
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if conn.Subprotocol() != "chat.v1" {
+		t.Fatalf("expected the chat.v1 subprotocol, got %q", conn.Subprotocol())
+	}
+	if frame := readFrame(t, conn); frame.Type != "welcome" || frame.Payload["version"] != float64(1) {
+		t.Fatalf("expected a version 1 welcome frame, got %+v", frame)
+	}
+
+	_, res, err := websocket.DefaultDialer.Dial(url+"&version=99", nil)
+	if err == nil || res == nil || res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected status %d for an unsupported version, got %v", http.StatusBadRequest, err)
+	}
+}
+
+func TestWebSocketTypingIndicators(t *testing.T) {
+	t.Parallel()
+
+	typist := signUp(t, "typist")
+	watcher := signUp(t, "watcher")
+	befriend(t, typist, watcher)
+
+	typistConn := dialSocket(t, typist)
+	watcherConn := dialSocket(t, watcher)
+
+	for _, frameType := range []string{"typing.start", "typing.stop"} {
+		writeFrame(t, typistConn, frameType, "", map[string]interface{}{
+			"recipientID": watcher.ID,
+		})
+
+		frame := readFrame(t, watcherConn)
+		if frame.Type != frameType || frame.Payload["userID"] != typist.ID {
+			t.Fatalf("expected %s from the typist, got %+v", frameType, frame)
+		}
+	}
+
+	// typing indicators are never persisted
+	page := struct {
+		Messages []interface{} `json:"messages"`
+	}{}
+	res, body := request(t, http.MethodGet, "/conversations/"+watcher.ID+"/messages", typist.Token, nil)
+	decode(t, body, &page)
+	if res.StatusCode != http.StatusOK || len(page.Messages) != 0 {
+		t.Fatalf("expected an empty conversation, got %d: %s", res.StatusCode, body)
+	}
+}
+
+func TestWebSocketPresence(t *testing.T) {
+	t.Parallel()
+
+	user := signUp(t, "present")
+	friend := signUp(t, "observer")
+	befriend(t, user, friend)
+
+	presence := func() map[string]interface{} {
+		var presences []map[string]interface{}
+		res, body := request(t, http.MethodGet, "/friends/presence", friend.Token, nil)
+		decode(t, body, &presences)
+		if res.StatusCode != http.StatusOK || len(presences) != 1 || presences[0]["userID"] != user.ID {
+			t.Fatalf("expected the presence of a single friend, got %d: %s", res.StatusCode, body)
+		}
+		return presences[0]
+	}
+	if status := presence()["status"]; status != "offline" {
+		t.Fatalf("expected the friend to start offline, got %v", status)
+	}
+
+	friendConn := dialSocket(t, friend)
+	userConn := dialSocket(t, user)
+
+	frame := readPresence(t, friendConn)
+	if frame.Payload["userID"] != user.ID || frame.Payload["status"] != "online" {
+		t.Fatalf("expected the friend to come online, got %+v", frame)
+	}
+
+	writeFrame(t, userConn, "presence.update", "", map[string]interface{}{"status": "away"})
+	if frame = readPresence(t, friendConn); frame.Payload["status"] != "away" {
+		t.Fatalf("expected the friend to be away, got %+v", frame)
+	}
+	if status := presence()["status"]; status != "away" {
+		t.Fatalf("expected the friend to be away, got %v", status)
+	}
+
+	userConn.Close()
+	if frame = readPresence(t, friendConn); frame.Payload["status"] != "offline" || frame.Payload["lastSeen"] == nil {
+		t.Fatalf("expected the friend to go offline, got %+v", frame)
+	}
+	if last := presence(); last["status"] != "offline" || last["lastSeen"] == nil {
+		t.Fatalf("expected the friend to be offline with a last seen time, got %v", last)
+	}
+}
+
+func TestWebSocketResume(t *testing.T) {
+	t.Parallel()
+
+	sender := signUp(t, "resumesender")
+	recipient := signUp(t, "resumer")
+	befriend(t, sender, recipient)
+
+	senderConn := dialSocket(t, sender)
+	recipientConn := dialSocket(t, recipient)
+
+	message := map[string]interface{}{"recipientID": recipient.ID, "content": "while online"}
+	sendMessage(t, senderConn, message)
+	seen := readFrame(t, recipientConn)
+	if seen.Type != "message.new" || seen.Seq == 0 {
+		t.Fatalf("expected a sequenced message, got %+v", seen)
+	}
+
+	// messages sent while the recipient is away are replayed once it resumes
+	recipientConn.Close()
+	eventually(t, func() bool {
+		var presences []map[string]interface{}
+		_, body := request(t, http.MethodGet, "/friends/presence", sender.Token, nil)
+		decode(t, body, &presences)
+		return len(presences) == 1 && presences[0]["status"] == "offline"
+	})
+
+	message["content"] = "while away"
+	sendMessage(t, senderConn, message)
+	sendMessage(t, senderConn, message)
+
+	recipientConn = dialSocket(t, recipient)
+	writeFrame(t, recipientConn, "resume", "", map[string]interface{}{"lastSeq": seen.Seq})
+	for i := int64(1); i <= 2; i++ {
+		missed := readFrame(t, recipientConn)
+		if missed.Type != "message.new" || missed.Seq != seen.Seq+i || missed.Payload["content"] != "while away" {
+			t.Fatalf("expected missed message %d, got %+v", seen.Seq+i, missed)
+		}
+	}
+	if resumed := readFrame(t, recipientConn); resumed.Type != "resumed" || resumed.Payload["lastSeq"] != float64(seen.Seq+2) {
+		t.Fatalf("expected the resume to complete, got %+v", resumed)
+	}
+
+	// nothing is replayed when resuming from the latest frame
+	writeFrame(t, recipientConn, "resume", "", map[string]interface{}{"lastSeq": seen.Seq + 2})
+	if resumed := readFrame(t, recipientConn); resumed.Type != "resumed" || resumed.Payload["lastSeq"] != float64(seen.Seq+2) {
+		t.Fatalf("expected the resume to complete straight away, got %+v", resumed)
+	}
+
+	// unknown sequence numbers cannot be resumed from
+	writeFrame(t, recipientConn, "resume", "", map[string]interface{}{"lastSeq": seen.Seq + 100})
+	if resync := readFrame(t, recipientConn); resync.Type != "resync" || resync.Payload["lastSeq"] != float64(seen.Seq+2) {
+		t.Fatalf("expected a resync, got %+v", resync)
+	}
+}
+
+func TestWebSocketKeepalive(t *testing.T) {
+	t.Parallel()
+
+	t.Run("unresponsive clients", func(t *testing.T) {
+		t.Parallel()
+
+		conn := dialSocket(t, signUp(t, "unresponsive"))
+		conn.SetPingHandler(func(string) error { return nil })
+
+		// the server gives up once the pong wait runs out, well before this deadline
+		conn.SetReadDeadline(time.Now().Add(15 * time.Second))
+		var err error
+		for err == nil {
+			_, _, err = conn.ReadMessage()
+		}
+		if isTimeout(err) {
+			t.Fatal("expected the server to close the socket")
+		}
+
+		if evictions := metric(t, `chat_websocket_evictions_total{reason="pong_timeout"}`); evictions == 0 {
+			t.Fatal("expected the eviction to be counted")
+		}
+	})
+
+	t.Run("responsive clients", func(t *testing.T) {
+		t.Parallel()
+
+		conn := dialSocket(t, signUp(t, "responsive"))
+
+		// reading answers pings, keeping the socket open past the pong wait
+		conn.SetReadDeadline(time.Now().Add(7 * time.Second))
+		if _, _, err := conn.ReadMessage(); !isTimeout(err) {
+			t.Fatalf("expected the socket to stay open, got %v", err)
+		}
+	})
+
+	t.Run("oversized frames", func(t *testing.T) {
+		t.Parallel()
+
+		sender := signUp(t, "verbose")
+		recipient := signUp(t, "overwhelmed")
+		befriend(t, sender, recipient)
+
+		conn := dialSocket(t, sender)
+		writeFrame(t, conn, "message.send", "too-long", map[string]string{
+			"recipientID": recipient.ID,
+			"content":     strings.Repeat("a", 5000),
+		})
+
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var err error
+		for err == nil {
+			_, _, err = conn.ReadMessage()
+		}
+		if !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+			t.Fatalf("expected the socket to close as the message is too big, got %v", err)
+		}
+	})
+}
+
+// isTimeout reports whether the error is a network timeout.
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
@@ -1,43 +1,77 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/Mutay1/chat-backend/cmd/api/controllers"
 	"github.com/Mutay1/chat-backend/cmd/api/internal"
 	"github.com/Mutay1/chat-backend/cmd/api/routes"
+	"github.com/Mutay1/chat-backend/domain/broker"
+	"github.com/Mutay1/chat-backend/domain/mailer"
+	"github.com/Mutay1/chat-backend/domain/presence"
 	"github.com/Mutay1/chat-backend/domain/repository"
-	"github.com/Mutay1/chat-backend/infrastructure/database"
-	"go.mongodb.org/mongo-driver/mongo"
-	"log"
+	"go.uber.org/zap"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
 // serveApp launches the server and handles its shutdown
-func serveApp(config internal.Config, db *mongo.Database) error {
-	// launch WebSocket server manager
-	go controllers.Manager.Start()
-
+func serveApp(config internal.Config, repositories repository.Repositories, messageBroker broker.Broker, presenceTracker presence.Tracker, messageMailer mailer.Mailer, logger *zap.Logger) error {
 	app := internal.Application{
-		Config: config,
-		Repositories: repository.Repositories{
-			Users: database.UserController{Db: db},
-		},
+		Config:       config,
+		Repositories: repositories,
+		Broker:       messageBroker,
+		Presence:     presenceTracker,
+		Logger:       logger,
+		Mailer:       messageMailer,
 	}
 
+	// launch WebSocket server manager
+	go controllers.Manager.Start(app)
+
 	srv := http.Server{
 		Addr:         fmt.Sprintf(":%d", app.Config.Port),
 		Handler:      routes.Router(app),
 		IdleTimeout:  1 * time.Minute,
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
+		ErrorLog:     zap.NewStdLog(logger),
 	}
 
+	// shut down gracefully on SIGINT or SIGTERM
+	shutdownError := make(chan error)
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+		logger.Info("shutting down server", zap.String("signal", s.String()))
+
+		ctx, cancel := context.WithTimeout(context.Background(), app.Config.ShutdownTimeout)
+		defer cancel()
+
+		// stop accepting connections and wait for in-flight requests, which leaves the hijacked sockets to the hub,
+		// whose queued frames are flushed even if some requests did not complete in time
+		err := srv.Shutdown(ctx)
+		if managerErr := controllers.Manager.Shutdown(ctx); err == nil {
+			err = managerErr
+		}
+		shutdownError <- err
+	}()
+
 	// start server
-	if err := srv.ListenAndServe(); err != nil {
+	logger.Info("starting server", zap.Int("port", app.Config.Port), zap.String("env", app.Config.Env), zap.String("version", app.Config.Version))
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	if err := <-shutdownError; err != nil {
 		return err
 	}
 
-	log.Println("stopped server")
+	logger.Info("stopped server")
 	return nil
 }
@@ -0,0 +1,23 @@
+package broker
+
+// Delivery is a frame published to a user subscribed to by this node.
+type Delivery struct {
+	UserID string
+	Frame  []byte
+}
+
+// Broker relays WebSocket frames between the nodes serving the API.
+// Every node subscribes to the users whose sockets it holds,
+// so frames published to a user reach them whichever node they are connected to.
+type Broker interface {
+	// Publish sends a frame to every node subscribed to the user, if any.
+	Publish(userId string, frame []byte) error
+	// Subscribe starts delivering the frames published to the user to this node.
+	Subscribe(userId string) error
+	// Unsubscribe stops delivering the frames published to the user to this node.
+	Unsubscribe(userId string) error
+	// Deliveries returns the channel frames published to subscribed users are received on, in publishing order.
+	Deliveries() <-chan Delivery
+	// Close stops the broker and closes the deliveries channel.
+	Close() error
+}
@@ -0,0 +1,39 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Message is a plain text email sent to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers the emails the API sends to users, such as verification links.
+type Mailer interface {
+	// Send delivers the message, returning once it has been handed over.
+	Send(message Message) error
+}
+
+// Format renders the message as an RFC 5322 email sent by from at the given date.
+// Line breaks are stripped from the headers so user input cannot inject new ones.
+func (m Message) Format(from string, date time.Time) []byte {
+	header := strings.NewReplacer("\r", "", "\n", "")
+
+	var email bytes.Buffer
+	fmt.Fprintf(&email, "From: %s\r\n", header.Replace(from))
+	fmt.Fprintf(&email, "To: %s\r\n", header.Replace(m.To))
+	fmt.Fprintf(&email, "Subject: %s\r\n", header.Replace(m.Subject))
+	fmt.Fprintf(&email, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	email.WriteString("MIME-Version: 1.0\r\n")
+	email.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	email.WriteString("\r\n")
+	email.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n"))
+
+	return email.Bytes()
+}
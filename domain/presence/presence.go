@@ -0,0 +1,18 @@
+package presence
+
+import "github.com/Mutay1/chat-backend/models"
+
+// Tracker keeps the presence of users, shared by the nodes serving the API.
+// It counts the sockets of every user across nodes, so users are only online while connected to any of them.
+type Tracker interface {
+	// Connect counts a new socket of the user, reporting whether it is their first on any node,
+	// which brings them online.
+	Connect(userId string) (presence models.Presence, first bool, err error)
+	// Disconnect uncounts a socket of the user, reporting whether it was their last on any node,
+	// which takes them offline and records when they were last seen.
+	Disconnect(userId string) (presence models.Presence, last bool, err error)
+	// SetStatus updates the status of a connected user, leaving users with no sockets offline.
+	SetStatus(userId string, status string) (models.Presence, error)
+	// Get retrieves the presence of the given users, in order, offline for those who never connected.
+	Get(userIds []string) ([]models.Presence, error)
+}
@@ -0,0 +1,7 @@
+package repository
+
+import "github.com/Mutay1/chat-backend/models"
+
+type AuditRepository interface {
+	Create(event models.AuditEvent) error
+}
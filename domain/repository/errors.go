@@ -4,5 +4,6 @@ import "errors"
 
 var (
 	ErrDuplicateDetails = errors.New("username or email already exists")
+	ErrDuplicateRecord  = errors.New("record already exists")
 	ErrRecordNotFound   = errors.New("no matching record found")
 )
@@ -0,0 +1,9 @@
+package repository
+
+import "github.com/Mutay1/chat-backend/models"
+
+type EventRepository interface {
+	Append(event models.Event) (models.Event, error)
+	GetAfter(userId string, seq int64, limit int64) ([]models.Event, error)
+	LastSeq(userId string) (int64, error)
+}
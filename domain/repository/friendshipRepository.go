@@ -0,0 +1,15 @@
+package repository
+
+import "github.com/Mutay1/chat-backend/models"
+
+type FriendshipRepository interface {
+	Create(friendship models.Friendship) (models.Friendship, error)
+	GetById(id string) (models.Friendship, error)
+	GetSent(userId string) ([]models.Friendship, error)
+	GetReceived(userId string) ([]models.Friendship, error)
+	GetFriends(userId string) ([]models.Friendship, error)
+	GetByPair(userId string, otherUserId string) (models.Friendship, error)
+	Accept(id string, recipientId string) error
+	Decline(id string, userId string) error
+	UpdateMember(member models.Friend) error
+}
@@ -0,0 +1,15 @@
+package repository
+
+import "github.com/Mutay1/chat-backend/models"
+
+type GroupRepository interface {
+	Create(group models.Group) (models.Group, error)
+	GetById(id string) (models.Group, error)
+	GetByMember(userId string) ([]models.Group, error)
+	UpdateDetails(group models.Group) error
+	AddMember(id string, member models.GroupMember) error
+	RemoveMember(id string, userId string) error
+	Leave(id string, member models.GroupMember, successorId string) error
+	UpdateMemberRole(id string, userId string, role string) error
+	Delete(id string) error
+}
@@ -0,0 +1,6 @@
+package repository
+
+type HealthRepository interface {
+	// Ping returns an error if the underlying store cannot serve requests.
+	Ping() error
+}
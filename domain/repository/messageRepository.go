@@ -0,0 +1,11 @@
+package repository
+
+import "github.com/Mutay1/chat-backend/models"
+
+type MessageRepository interface {
+	Create(message models.Message) (models.Message, error)
+	GetById(conversationId string, id string) (models.Message, error)
+	GetByClientId(sender string, clientId string) (models.Message, error)
+	GetByConversation(conversationId string, before string, limit int64) ([]models.Message, error)
+	CountUnread(conversationId string, userId string, readUpTo string) (int64, error)
+}
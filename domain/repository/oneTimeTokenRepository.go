@@ -0,0 +1,9 @@
+package repository
+
+import "github.com/Mutay1/chat-backend/models"
+
+type OneTimeTokenRepository interface {
+	Create(token models.OneTimeToken) (models.OneTimeToken, error)
+	GetLatest(userId string, purpose string) (models.OneTimeToken, error)
+	Consume(purpose string, hash string) (models.OneTimeToken, error)
+}
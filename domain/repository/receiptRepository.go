@@ -0,0 +1,10 @@
+package repository
+
+import "github.com/Mutay1/chat-backend/models"
+
+type ReceiptRepository interface {
+	GetByConversation(conversationId string) ([]models.Receipt, error)
+	GetByUser(conversationId string, userId string) (models.Receipt, error)
+	MarkDelivered(conversationId string, userId string, messageId string) error
+	MarkRead(conversationId string, userId string, messageId string) error
+}
@@ -2,5 +2,14 @@ package repository
 
 // Repositories encapsulates all available repositories for easy reuse.
 type Repositories struct {
-	Users UserRepository
+	Users       UserRepository
+	Friendships FriendshipRepository
+	Messages    MessageRepository
+	Groups      GroupRepository
+	Receipts    ReceiptRepository
+	Events      EventRepository
+	Health      HealthRepository
+	Tokens      OneTimeTokenRepository
+	Audit       AuditRepository
+	Sessions    SessionRepository
 }
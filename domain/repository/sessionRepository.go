@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"github.com/Mutay1/chat-backend/models"
+	"time"
+)
+
+type SessionRepository interface {
+	Create(session models.Session) (models.Session, error)
+	GetById(id string) (models.Session, error)
+	GetByUser(userId string) ([]models.Session, error)
+	Rotate(id string, currentHash string, newHash string, lastUsedAt time.Time, expiresAt time.Time) error
+	Delete(userId string, id string) error
+	DeleteByUser(userId string, exceptId string) error
+}
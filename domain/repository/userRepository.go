@@ -6,6 +6,8 @@ type UserRepository interface {
 	Create(user models.User) (models.User, error)
 	GetById(id string) (models.User, error)
 	GetByEmail(email string) (models.User, error)
-	GetByRefreshToken(refreshToken string) (models.User, error)
-	UpdateRefreshToken(userId string, newRefreshToken string) error
+	GetByUsername(username string) (models.User, error)
+	UpdateProfile(user models.User) (models.User, error)
+	VerifyEmail(userId string, email string) error
+	UpdatePassword(userId string, password string) error
 }
@@ -1,17 +1,95 @@
 package helper
 
 import (
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
-	"log"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
 	"net/http"
+	"strings"
 )
 
+// Error codes, which clients can rely on unlike messages.
+const (
+	ErrorInvalidRequest     = "invalid_request"
+	ErrorValidationFailed   = "validation_failed"
+	ErrorUnauthorized       = "unauthorized"
+	ErrorInvalidCredentials = "invalid_credentials"
+	ErrorInvalidToken       = "invalid_token"
+	ErrorForbidden          = "forbidden"
+	ErrorEmailNotVerified   = "email_not_verified"
+	ErrorNotFound           = "not_found"
+	ErrorConflict           = "conflict"
+	ErrorUnprocessable      = "unprocessable_entity"
+	ErrorTooManyRequests    = "too_many_requests"
+	ErrorUpstreamFailed     = "upstream_failed"
+	ErrorInternal           = "internal_error"
+)
+
+// Error is the body of every error response, under the "error" key.
+// Details maps the fields of the request that failed validation to the reason why.
+type Error struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+func (e Error) Error() string {
+	return e.Message
+}
+
+// AbortWithError aborts the request with the given status and error.
+func AbortWithError(ctx *gin.Context, status int, code string, message string) {
+	ctx.AbortWithStatusJSON(status, gin.H{"error": Error{Code: code, Message: message}})
+}
+
+// HandleBindingError sends a 400 error response for a request body that could not be decoded or validated.
+// Validation errors detail every invalid field.
+func HandleBindingError(ctx *gin.Context, err error) {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		AbortWithError(ctx, http.StatusBadRequest, ErrorInvalidRequest, err.Error())
+		return
+	}
+
+	details := make(map[string]string, len(validationErrors))
+	for _, fieldError := range validationErrors {
+		details[fieldError.Field()] = validationReason(fieldError)
+	}
+
+	ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": Error{
+		Code:    ErrorValidationFailed,
+		Message: "the request is invalid",
+		Details: details,
+	}})
+}
+
+// validationReason describes the rule a field failed.
+func validationReason(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required", "required_without":
+		return "is required"
+	case "excluded_with":
+		return "must be omitted with " + fieldError.Param()
+	case "email":
+		return "must be a valid email"
+	case "min":
+		return "must be at least " + fieldError.Param()
+	case "max":
+		return "must be at most " + fieldError.Param()
+	case "len":
+		return "must be exactly " + fieldError.Param()
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fieldError.Param()), ", ")
+	default:
+		return fmt.Sprintf("failed the %s rule", fieldError.Tag())
+	}
+}
+
 // HandleInternalServerError logs the error and sends
 // a generic 500 error response to the client.
 func HandleInternalServerError(ctx *gin.Context, err error) {
-	log.Printf("internal server error: %s", err.Error())
-	ctx.AbortWithStatusJSON(
-		http.StatusInternalServerError,
-		gin.H{"error": "internal server error"},
-	)
+	Logger(ctx).Error("internal server error", zap.Error(err))
+	AbortWithError(ctx, http.StatusInternalServerError, ErrorInternal, "internal server error")
 }
@@ -0,0 +1,18 @@
+package helper
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// LoggerKey is the context key of the request-scoped logger.
+const LoggerKey = "logger"
+
+// Logger returns the logger of the request, which tags its entries with the request ID.
+// The global logger, a no-op unless replaced, is returned if the request has none.
+func Logger(ctx *gin.Context) *zap.Logger {
+	if logger, ok := ctx.Value(LoggerKey).(*zap.Logger); ok {
+		return logger
+	}
+	return zap.L()
+}
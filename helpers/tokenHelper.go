@@ -1,44 +1,96 @@
 package helper
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
-	"log"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
-// GenerateTokens generates both the detailed token and refresh token
-func GenerateTokens(jwtSecret string, userId string) (signedAccessToken string, signedRefreshToken string, err error) {
+// Token lifetimes.
+const (
+	AccessTokenLifetime  = 24 * time.Hour
+	RefreshTokenLifetime = 7 * 24 * time.Hour
+)
+
+// Token types, so refresh tokens can't be used as access tokens and the other way around.
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+)
+
+// Claims are the claims of the tokens issued to users.
+// SessionID is the session the token belongs to, empty for tokens issued before sessions existed.
+type Claims struct {
+	Type      string `json:"typ,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+	jwt.StandardClaims
+}
+
+// GenerateTokens generates both the detailed token and refresh token of a session
+func GenerateTokens(jwtSecret string, userId string, sessionId string) (signedAccessToken string, signedRefreshToken string, err error) {
 	// generate access token with a lifetime of 24 hours
-	accessClaims := jwt.StandardClaims{
-		Subject:   userId,
-		ExpiresAt: time.Now().Local().Add(24 * time.Hour).Unix(),
+	accessClaims := Claims{
+		Type:      tokenTypeAccess,
+		SessionID: sessionId,
+		StandardClaims: jwt.StandardClaims{
+			Subject:   userId,
+			ExpiresAt: time.Now().Local().Add(AccessTokenLifetime).Unix(),
+		},
 	}
 
-	// generate refresh token with a lifetime of 1 week
-	refreshClaims := jwt.StandardClaims{
-		ExpiresAt: time.Now().Local().Add(7 * 24 * time.Hour).Unix(),
+	// generate refresh token with a lifetime of 1 week, made unique by a random ID
+	// as tokens issued within the same second would otherwise be identical
+	tokenId := make([]byte, 16)
+	if _, err = rand.Read(tokenId); err != nil {
+		return
+	}
+	refreshClaims := Claims{
+		Type:      tokenTypeRefresh,
+		SessionID: sessionId,
+		StandardClaims: jwt.StandardClaims{
+			Id:        hex.EncodeToString(tokenId),
+			Subject:   userId,
+			ExpiresAt: time.Now().Local().Add(RefreshTokenLifetime).Unix(),
+		},
 	}
 
 	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims).SignedString([]byte(jwtSecret))
-	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString([]byte(jwtSecret))
+	if err != nil {
+		return "", "", err
+	}
 
+	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString([]byte(jwtSecret))
 	if err != nil {
-		log.Panic(err)
-		return
+		return "", "", err
 	}
 
-	return token, refreshToken, err
+	return token, refreshToken, nil
 }
 
-// ValidateToken validates the provided JWT.
+// ValidateToken validates the provided access token.
 // An error is returned if the token is invalid or expired.
-func ValidateToken(jwtSecret string, signedToken string) (*jwt.StandardClaims, error) {
+func ValidateToken(jwtSecret string, signedToken string) (*Claims, error) {
+	return validateToken(jwtSecret, signedToken, tokenTypeAccess)
+}
+
+// ValidateRefreshToken validates the provided refresh token.
+// An error is returned if the token is invalid or expired.
+func ValidateRefreshToken(jwtSecret string, signedToken string) (*Claims, error) {
+	return validateToken(jwtSecret, signedToken, tokenTypeRefresh)
+}
+
+// validateToken validates a JWT of the given type.
+// Tokens issued before types existed are accepted as either,
+// as only access tokens had a subject to authenticate with.
+func validateToken(jwtSecret string, signedToken string, tokenType string) (*Claims, error) {
 	// attempt to parse token
 	token, err := jwt.ParseWithClaims(
 		signedToken,
-		&jwt.StandardClaims{},
+		&Claims{},
 		func(token *jwt.Token) (interface{}, error) {
 			return []byte(jwtSecret), nil
 		},
@@ -48,10 +100,28 @@ func ValidateToken(jwtSecret string, signedToken string) (*jwt.StandardClaims, e
 	}
 
 	// extract claims from token
-	claims, ok := token.Claims.(*jwt.StandardClaims)
-	if !ok {
+	claims, ok := token.Claims.(*Claims)
+	if !ok || claims.Type != "" && claims.Type != tokenType {
 		return nil, errors.New("invalid or expired token")
 	}
 
 	return claims, nil
 }
+
+// GenerateOneTimeToken generates a random token to mail to a user, along with the hash to store in its place.
+func GenerateOneTimeToken() (token string, hash string, err error) {
+	secret := make([]byte, 32)
+	if _, err = rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	token = hex.EncodeToString(secret)
+	return token, HashToken(token), nil
+}
+
+// HashToken hashes a one-time or refresh token for storage.
+// Both are random enough for a fast unsalted hash, which lets them be looked up by hash.
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,23 @@
+package database
+
+import (
+	"context"
+	"github.com/Mutay1/chat-backend/models"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+type AuditController struct {
+	Db *mongo.Database
+}
+
+const collectionAuditEvents = "auditEvents"
+
+// Create records an audit event.
+func (a AuditController) Create(event models.AuditEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := a.Db.Collection(collectionAuditEvents).InsertOne(ctx, event)
+	return err
+}
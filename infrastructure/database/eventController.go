@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/Mutay1/chat-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type EventController struct {
+	Db *mongo.Database
+}
+
+const (
+	collectionEvents    = "events"
+	collectionSequences = "sequences"
+)
+
+// eventRetention is how long events are kept for reconnecting clients.
+const eventRetention = 7 * 24 * time.Hour
+
+// Append stores an event of a user, assigning it the next sequence number of that user.
+func (e EventController) Append(event models.Event) (models.Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// sequences are kept apart from the events so they survive the expiry of the latest ones
+	sequence := struct {
+		Seq int64 `bson:"seq"`
+	}{}
+	err := e.Db.Collection(collectionSequences).FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": event.UserID},
+		bson.M{"$inc": bson.M{"seq": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&sequence)
+	if err != nil {
+		return models.Event{}, err
+	}
+
+	event.Seq = sequence.Seq
+	if _, err = e.Db.Collection(collectionEvents).InsertOne(ctx, event); err != nil {
+		return models.Event{}, err
+	}
+
+	return event, nil
+}
+
+// GetAfter retrieves up to limit events of a user following the given sequence number, in order.
+// Events that have expired are skipped, so callers should check the sequence numbers are contiguous.
+func (e EventController) GetAfter(userId string, seq int64, limit int64) ([]models.Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := e.Db.Collection(collectionEvents).Find(
+		ctx,
+		bson.M{"userID": userId, "seq": bson.M{"$gt": seq}},
+		options.Find().SetSort(bson.M{"seq": 1}).SetLimit(limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	foundEvents := []models.Event{}
+	if err = cursor.All(ctx, &foundEvents); err != nil {
+		return nil, err
+	}
+
+	return foundEvents, nil
+}
+
+// LastSeq retrieves the sequence number of the latest event of a user, 0 if they have none.
+func (e EventController) LastSeq(userId string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sequence := struct {
+		Seq int64 `bson:"seq"`
+	}{}
+	err := e.Db.Collection(collectionSequences).FindOne(ctx, bson.M{"_id": userId}).Decode(&sequence)
+	if err != nil {
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			return 0, nil
+
+		default:
+			return 0, err
+		}
+	}
+
+	return sequence.Seq, nil
+}
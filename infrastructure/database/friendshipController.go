@@ -0,0 +1,269 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/Mutay1/chat-backend/domain/repository"
+	"github.com/Mutay1/chat-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+type FriendshipController struct {
+	Db *mongo.Database
+}
+
+const collectionFriendships = "friendships"
+
+// Create stores a new friend request.
+// repository.ErrDuplicateRecord is returned if a request already exists between both users, regardless of direction.
+func (f FriendshipController) Create(friendship models.Friendship) (models.Friendship, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// check if any pre-existing request between both users exists
+	count, err := f.Db.Collection(collectionFriendships).CountDocuments(
+		ctx,
+		pairFilter(friendship.Requester.ID, friendship.Recipient.ID),
+	)
+
+	if err != nil {
+		return models.Friendship{}, err
+	}
+
+	if count > 0 {
+		return models.Friendship{}, repository.ErrDuplicateRecord
+	}
+
+	if _, err := f.Db.Collection(collectionFriendships).InsertOne(ctx, friendship); err != nil {
+		return models.Friendship{}, err
+	}
+
+	return friendship, nil
+}
+
+// GetById retrieves an existing friendship via its ID.
+// repository.ErrRecordNotFound is returned if no qualifying friendship is found.
+func (f FriendshipController) GetById(id string) (models.Friendship, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Friendship{}, repository.ErrRecordNotFound
+	}
+
+	return f.findOne(bson.M{"_id": objectId})
+}
+
+// GetSent retrieves the pending requests sent by the user with the given id.
+func (f FriendshipController) GetSent(userId string) ([]models.Friendship, error) {
+	objectId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return []models.Friendship{}, nil
+	}
+
+	return f.find(bson.M{
+		"accepted":      false,
+		"requester._id": objectId,
+	})
+}
+
+// GetReceived retrieves the pending requests received by the user with the given id.
+func (f FriendshipController) GetReceived(userId string) ([]models.Friendship, error) {
+	objectId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return []models.Friendship{}, nil
+	}
+
+	return f.find(bson.M{
+		"accepted":      false,
+		"recipient._id": objectId,
+	})
+}
+
+// GetFriends retrieves the accepted friendships the user with the given id is part of.
+func (f FriendshipController) GetFriends(userId string) ([]models.Friendship, error) {
+	objectId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return []models.Friendship{}, nil
+	}
+
+	return f.find(bson.M{
+		"accepted": true,
+		"$or": bson.A{
+			bson.M{"requester._id": objectId},
+			bson.M{"recipient._id": objectId},
+		},
+	})
+}
+
+// GetByPair retrieves the friendship between both users, regardless of who sent the request.
+// repository.ErrRecordNotFound is returned if no qualifying friendship is found.
+func (f FriendshipController) GetByPair(userId string, otherUserId string) (models.Friendship, error) {
+	objectId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return models.Friendship{}, repository.ErrRecordNotFound
+	}
+
+	otherObjectId, err := primitive.ObjectIDFromHex(otherUserId)
+	if err != nil {
+		return models.Friendship{}, repository.ErrRecordNotFound
+	}
+
+	return f.findOne(pairFilter(objectId, otherObjectId))
+}
+
+// Accept marks the pending request with the given id as accepted.
+// Only the recipient of a request can accept it.
+// repository.ErrRecordNotFound is returned if no qualifying request is found.
+func (f FriendshipController) Accept(id string, recipientId string) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return repository.ErrRecordNotFound
+	}
+
+	recipientObjectId, err := primitive.ObjectIDFromHex(recipientId)
+	if err != nil {
+		return repository.ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"_id":           objectId,
+		"recipient._id": recipientObjectId,
+		"accepted":      false,
+	}
+	updates := bson.M{
+		"accepted":  true,
+		"updatedAt": time.Now().UTC(),
+	}
+
+	result, err := f.Db.Collection(collectionFriendships).UpdateOne(ctx, filter, bson.M{"$set": updates})
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return repository.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Decline deletes the friendship with the given id.
+// Either member of a friendship can decline it.
+// repository.ErrRecordNotFound is returned if no qualifying friendship is found.
+func (f FriendshipController) Decline(id string, userId string) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return repository.ErrRecordNotFound
+	}
+
+	userObjectId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return repository.ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := f.Db.Collection(collectionFriendships).DeleteOne(ctx, bson.M{
+		"_id": objectId,
+		"$or": bson.A{
+			bson.M{"requester._id": userObjectId},
+			bson.M{"recipient._id": userObjectId},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return repository.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// UpdateMember refreshes the profile details of the given member across all their friendships.
+// The per-member flags are left untouched.
+func (f FriendshipController) UpdateMember(member models.Friend) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, side := range []string{"requester", "recipient"} {
+		updates := bson.M{
+			side + ".firstName": member.FirstName,
+			side + ".lastName":  member.LastName,
+			side + ".username":  member.Username,
+			side + ".avatarURL": member.AvatarURL,
+			side + ".status":    member.Status,
+			side + ".about":     member.About,
+			side + ".city":      member.City,
+		}
+
+		_, err := f.Db.Collection(collectionFriendships).UpdateMany(
+			ctx,
+			bson.M{side + "._id": member.ID},
+			bson.M{"$set": updates},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findOne retrieves the single friendship matching the filter.
+// repository.ErrRecordNotFound is returned if no qualifying friendship is found.
+func (f FriendshipController) findOne(filter bson.M) (models.Friendship, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// empty struct to populate with fetched friendship data
+	foundFriendship := models.Friendship{}
+
+	err := f.Db.Collection(collectionFriendships).FindOne(ctx, filter).Decode(&foundFriendship)
+	if err != nil {
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			return models.Friendship{}, repository.ErrRecordNotFound
+
+		default:
+			return models.Friendship{}, err
+		}
+	}
+
+	return foundFriendship, nil
+}
+
+// find retrieves all friendships matching the filter.
+func (f FriendshipController) find(filter bson.M) ([]models.Friendship, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := f.Db.Collection(collectionFriendships).Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	foundFriendships := []models.Friendship{}
+	if err = cursor.All(ctx, &foundFriendships); err != nil {
+		return nil, err
+	}
+
+	return foundFriendships, nil
+}
+
+// pairFilter matches the friendship between both users, regardless of who sent the request.
+func pairFilter(userId primitive.ObjectID, otherUserId primitive.ObjectID) bson.M {
+	return bson.M{
+		"$or": bson.A{
+			bson.M{"requester._id": userId, "recipient._id": otherUserId},
+			bson.M{"requester._id": otherUserId, "recipient._id": userId},
+		},
+	}
+}
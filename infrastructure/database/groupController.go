@@ -0,0 +1,228 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/Mutay1/chat-backend/domain/repository"
+	"github.com/Mutay1/chat-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+type GroupController struct {
+	Db *mongo.Database
+}
+
+const collectionGroups = "groups"
+
+// Create stores a new group.
+func (g GroupController) Create(group models.Group) (models.Group, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := g.Db.Collection(collectionGroups).InsertOne(ctx, group); err != nil {
+		return models.Group{}, err
+	}
+
+	return group, nil
+}
+
+// GetById retrieves an existing group via its ID.
+// repository.ErrRecordNotFound is returned if no qualifying group is found.
+func (g GroupController) GetById(id string) (models.Group, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Group{}, repository.ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// empty struct to populate with fetched group data
+	foundGroup := models.Group{}
+
+	err = g.Db.Collection(collectionGroups).FindOne(ctx, bson.M{"_id": objectId}).Decode(&foundGroup)
+	if err != nil {
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			return models.Group{}, repository.ErrRecordNotFound
+
+		default:
+			return models.Group{}, err
+		}
+	}
+
+	return foundGroup, nil
+}
+
+// GetByMember retrieves the groups the user with the given id is a member of.
+func (g GroupController) GetByMember(userId string) ([]models.Group, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := g.Db.Collection(collectionGroups).Find(ctx, bson.M{"members.userID": userId})
+	if err != nil {
+		return nil, err
+	}
+
+	foundGroups := []models.Group{}
+	if err = cursor.All(ctx, &foundGroups); err != nil {
+		return nil, err
+	}
+
+	return foundGroups, nil
+}
+
+// UpdateDetails overwrites the name and avatar of the given group.
+// repository.ErrRecordNotFound is returned if no qualifying group is found.
+func (g GroupController) UpdateDetails(group models.Group) error {
+	updates := bson.M{
+		"name":      group.Name,
+		"avatarURL": group.AvatarURL,
+		"updatedAt": group.UpdatedAt,
+	}
+
+	return g.updateOne(bson.M{"_id": group.ID}, bson.M{"$set": updates})
+}
+
+// AddMember adds a new member to the group with the given id.
+// repository.ErrDuplicateRecord is returned if the user is already a member,
+// and repository.ErrRecordNotFound if no qualifying group is found.
+func (g GroupController) AddMember(id string, member models.GroupMember) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return repository.ErrRecordNotFound
+	}
+
+	err = g.updateOne(
+		bson.M{"_id": objectId, "members.userID": bson.M{"$ne": member.UserID}},
+		bson.M{
+			"$push": bson.M{"members": member},
+			"$set":  bson.M{"updatedAt": time.Now().UTC()},
+		},
+	)
+
+	// distinguish a missing group from an existing member
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		if _, err = g.GetById(id); err == nil {
+			return repository.ErrDuplicateRecord
+		}
+	}
+
+	return err
+}
+
+// RemoveMember removes the user with the given id from the group.
+// repository.ErrRecordNotFound is returned if no qualifying group or member is found.
+func (g GroupController) RemoveMember(id string, userId string) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return repository.ErrRecordNotFound
+	}
+
+	return g.updateOne(
+		bson.M{"_id": objectId, "members.userID": userId},
+		bson.M{
+			"$pull": bson.M{"members": bson.M{"userID": userId}},
+			"$set":  bson.M{"updatedAt": time.Now().UTC()},
+		},
+	)
+}
+
+// Leave removes the member from the group with the given id, handing ownership to the successor with the given id, if any.
+// Both changes are applied by a single update, conditioned on the member still having the same role
+// and the successor still being a member, so that concurrent changes cannot leave the group without an owner.
+// repository.ErrRecordNotFound is returned if no qualifying group is found,
+// or if the member no longer has the same role or the successor is no longer a member.
+func (g GroupController) Leave(id string, member models.GroupMember, successorId string) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return repository.ErrRecordNotFound
+	}
+
+	conditions := bson.A{bson.M{"members": bson.M{"$elemMatch": bson.M{"userID": member.UserID, "role": member.Role}}}}
+	if successorId != "" {
+		conditions = append(conditions, bson.M{"members.userID": successorId})
+	}
+
+	// $pull and a positional $set cannot target the same array, so the members are rewritten by a pipeline
+	members := bson.M{"$map": bson.M{
+		"input": bson.M{"$filter": bson.M{
+			"input": "$members",
+			"cond":  bson.M{"$ne": bson.A{"$$this.userID", member.UserID}},
+		}},
+		"in": bson.M{"$cond": bson.A{
+			bson.M{"$eq": bson.A{"$$this.userID", successorId}},
+			bson.M{"$mergeObjects": bson.A{"$$this", bson.M{"role": models.GroupRoleOwner}}},
+			"$$this",
+		}},
+	}}
+
+	return g.updateOne(
+		bson.M{"_id": objectId, "$and": conditions},
+		bson.A{bson.M{"$set": bson.M{
+			"members":   members,
+			"updatedAt": time.Now().UTC(),
+		}}},
+	)
+}
+
+// UpdateMemberRole sets the role of the user with the given id within the group.
+// repository.ErrRecordNotFound is returned if no qualifying group or member is found.
+func (g GroupController) UpdateMemberRole(id string, userId string, role string) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return repository.ErrRecordNotFound
+	}
+
+	return g.updateOne(
+		bson.M{"_id": objectId, "members.userID": userId},
+		bson.M{"$set": bson.M{
+			"members.$.role": role,
+			"updatedAt":      time.Now().UTC(),
+		}},
+	)
+}
+
+// Delete removes the group with the given id.
+// repository.ErrRecordNotFound is returned if no qualifying group is found.
+func (g GroupController) Delete(id string) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return repository.ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := g.Db.Collection(collectionGroups).DeleteOne(ctx, bson.M{"_id": objectId})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return repository.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// updateOne applies the update, a document or a pipeline, to the single group matching the filter.
+// repository.ErrRecordNotFound is returned if no qualifying group is found.
+func (g GroupController) updateOne(filter bson.M, update interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := g.Db.Collection(collectionGroups).UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return repository.ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,21 @@
+package database
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"time"
+)
+
+type HealthController struct {
+	Db *mongo.Database
+}
+
+// Ping checks that the primary of the database is reachable.
+// Its timeout is short, as readiness probes should fail fast rather than hang.
+func (h HealthController) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	return h.Db.Client().Ping(ctx, readpref.Primary())
+}
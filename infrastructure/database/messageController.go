@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/Mutay1/chat-backend/domain/repository"
+	"github.com/Mutay1/chat-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type MessageController struct {
+	Db *mongo.Database
+}
+
+const collectionMessages = "messages"
+
+// Create stores a new message, assigning it an ID if it has none.
+// repository.ErrDuplicateRecord is returned if the sender already sent a message with the same client ID.
+func (m MessageController) Create(message models.Message) (models.Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if message.ID.IsZero() {
+		message.ID = primitive.NewObjectID()
+	}
+
+	// uniqueness of client IDs is enforced by an index, see createIndexes
+	if _, err := m.Db.Collection(collectionMessages).InsertOne(ctx, message); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return models.Message{}, repository.ErrDuplicateRecord
+		}
+		return models.Message{}, err
+	}
+
+	return message, nil
+}
+
+// GetById retrieves the message of a conversation with the given ID.
+// repository.ErrRecordNotFound is returned if no qualifying message is found.
+func (m MessageController) GetById(conversationId string, id string) (models.Message, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Message{}, repository.ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	message := models.Message{}
+	err = m.Db.Collection(collectionMessages).FindOne(ctx, bson.M{"_id": objectId, "conversationID": conversationId}).Decode(&message)
+	if err != nil {
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			return models.Message{}, repository.ErrRecordNotFound
+
+		default:
+			return models.Message{}, err
+		}
+	}
+
+	return message, nil
+}
+
+// GetByClientId retrieves the message a sender sent with the given client ID.
+// repository.ErrRecordNotFound is returned if no qualifying message is found.
+func (m MessageController) GetByClientId(sender string, clientId string) (models.Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	message := models.Message{}
+	err := m.Db.Collection(collectionMessages).FindOne(ctx, bson.M{"sender": sender, "clientID": clientId}).Decode(&message)
+	if err != nil {
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			return models.Message{}, repository.ErrRecordNotFound
+
+		default:
+			return models.Message{}, err
+		}
+	}
+
+	return message, nil
+}
+
+// GetByConversation retrieves up to limit messages of a conversation sent before the message with the given ID,
+// ordered from oldest to newest. An empty before starts from the latest message and a limit of 0 returns all of them.
+func (m MessageController) GetByConversation(conversationId string, before string, limit int64) ([]models.Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"conversationID": conversationId}
+	if before != "" {
+		beforeId, err := primitive.ObjectIDFromHex(before)
+		if err != nil {
+			return []models.Message{}, nil
+		}
+		filter["_id"] = bson.M{"$lt": beforeId}
+	}
+
+	// fetch newest first so the limit applies to the messages closest to the cursor
+	opts := options.Find().SetSort(bson.M{"_id": -1})
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+
+	cursor, err := m.Db.Collection(collectionMessages).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	foundMessages := []models.Message{}
+	if err = cursor.All(ctx, &foundMessages); err != nil {
+		return nil, err
+	}
+
+	// restore chronological order
+	for i, j := 0, len(foundMessages)-1; i < j; i, j = i+1, j-1 {
+		foundMessages[i], foundMessages[j] = foundMessages[j], foundMessages[i]
+	}
+
+	return foundMessages, nil
+}
+
+// CountUnread counts the messages of a conversation sent by others after the message with the given ID.
+// An empty readUpTo counts every message sent by others.
+func (m MessageController) CountUnread(conversationId string, userId string, readUpTo string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"conversationID": conversationId,
+		"sender":         bson.M{"$ne": userId},
+	}
+	if readUpTo != "" {
+		readUpToId, err := primitive.ObjectIDFromHex(readUpTo)
+		if err != nil {
+			return 0, nil
+		}
+		filter["_id"] = bson.M{"$gt": readUpToId}
+	}
+
+	return m.Db.Collection(collectionMessages).CountDocuments(ctx, filter)
+}
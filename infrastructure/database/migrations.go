@@ -0,0 +1,336 @@
+package database
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Migrate brings the collections and indexes of the database up to date.
+// Every step is idempotent so it can be safely rerun.
+func Migrate(db *mongo.Database, logger *zap.Logger) error {
+	if err := createIndexes(db); err != nil {
+		return err
+	}
+
+	migrated, err := migrateEmbeddedMessages(db)
+	if err != nil {
+		return err
+	}
+	logger.Info("migrated embedded messages", zap.Int("count", migrated))
+
+	migrated, err = migrateMessageFlags(db)
+	if err != nil {
+		return err
+	}
+	logger.Info("migrated message flags to receipts", zap.Int("count", migrated))
+
+	migrated, err = migrateEmailVerified(db)
+	if err != nil {
+		return err
+	}
+	logger.Info("marked existing users as verified", zap.Int("count", migrated))
+
+	migrated, err = migrateRefreshTokens(db)
+	if err != nil {
+		return err
+	}
+	logger.Info("removed refresh tokens issued before sessions", zap.Int("count", migrated))
+
+	return nil
+}
+
+// createIndexes creates the indexes needed by the repository queries.
+func createIndexes(db *mongo.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := db.Collection(collectionMessages).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "conversationID", Value: 1}, {Key: "_id", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// client IDs make resent messages idempotent, messages sent before they existed have none
+	_, err = db.Collection(collectionMessages).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "sender", Value: 1}, {Key: "clientID", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"clientID": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection(collectionGroups).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "members.userID", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection(collectionReceipts).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "conversationID", Value: 1}, {Key: "userID", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection(collectionEvents).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "userID", Value: 1}, {Key: "seq", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// clients offline for longer than the retention have to resync
+			Keys:    bson.D{{Key: "createdAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(eventRetention.Seconds())),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection(collectionOneTimeTokens).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "userID", Value: 1}, {Key: "purpose", Value: 1}, {Key: "createdAt", Value: -1}},
+		},
+		{
+			// tokens are removed as soon as they expire
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection(collectionAuditEvents).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "userID", Value: 1}, {Key: "createdAt", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection(collectionSessions).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "userID", Value: 1}, {Key: "lastUsedAt", Value: -1}},
+		},
+		{
+			// sessions are removed as soon as their refresh token expires
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	return err
+}
+
+// legacyFriendship is a friendship with messages still embedded in its members.
+// Both members hold identical copies, so only the requester's is read.
+// Messages are kept as raw documents so fields since dropped from models.Message survive the move.
+type legacyFriendship struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Requester struct {
+		Messages []bson.M `bson:"messages"`
+	} `bson:"requester"`
+}
+
+// migrateEmbeddedMessages moves the messages embedded in friendships into the messages collection.
+// Migrated messages get IDs derived from their friendship and position, so a friendship
+// interrupted midway is upserted again rather than duplicated on the next run.
+func migrateEmbeddedMessages(db *mongo.Database) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	cursor, err := db.Collection(collectionFriendships).Find(ctx, bson.M{
+		"$or": bson.A{
+			bson.M{"requester.messages": bson.M{"$exists": true}},
+			bson.M{"recipient.messages": bson.M{"$exists": true}},
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	migrated := 0
+	for cursor.Next(ctx) {
+		friendship := legacyFriendship{}
+		if err = cursor.Decode(&friendship); err != nil {
+			return migrated, err
+		}
+
+		for index, message := range friendship.Requester.Messages {
+			createdAt, _ := message["createdAt"].(primitive.DateTime)
+			message["_id"] = legacyMessageId(friendship.ID, index, createdAt.Time())
+			message["conversationID"] = friendship.ID.Hex()
+
+			_, err = db.Collection(collectionMessages).ReplaceOne(
+				ctx,
+				bson.M{"_id": message["_id"]},
+				message,
+				options.Replace().SetUpsert(true),
+			)
+			if err != nil {
+				return migrated, err
+			}
+			migrated++
+		}
+
+		_, err = db.Collection(collectionFriendships).UpdateOne(
+			ctx,
+			bson.M{"_id": friendship.ID},
+			bson.M{"$unset": bson.M{"requester.messages": "", "recipient.messages": ""}},
+		)
+		if err != nil {
+			return migrated, err
+		}
+	}
+
+	return migrated, cursor.Err()
+}
+
+// legacyMessageId deterministically derives the ID of an embedded message.
+// The timestamp portion is taken from the message so ID order still follows send order.
+func legacyMessageId(friendshipId primitive.ObjectID, index int, createdAt time.Time) primitive.ObjectID {
+	var id primitive.ObjectID
+	binary.BigEndian.PutUint32(id[0:4], uint32(createdAt.Unix()))
+
+	seed := make([]byte, len(friendshipId)+8)
+	copy(seed, friendshipId[:])
+	binary.BigEndian.PutUint64(seed[len(friendshipId):], uint64(index))
+	hash := sha1.Sum(seed)
+	copy(id[4:], hash[:8])
+
+	return id
+}
+
+// migrateMessageFlags turns the per-message delivered and read flags into per-user receipt cursors,
+// pointing each cursor at the latest flagged message, then strips the flags from the messages.
+func migrateMessageFlags(db *mongo.Database) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	// direct messages hold the flags of their recipient while group messages hold one receipt per member
+	pipelines := []mongo.Pipeline{
+		{
+			{{Key: "$match", Value: bson.M{"recipientID": bson.M{"$nin": bson.A{"", nil}}, "delivered": true}}},
+			{{Key: "$group", Value: bson.M{
+				"_id":         bson.M{"conversationID": "$conversationID", "userID": "$recipientID"},
+				"deliveredID": bson.M{"$max": "$_id"},
+				"readID":      bson.M{"$max": bson.M{"$cond": bson.A{"$read", "$_id", nil}}},
+			}}},
+		},
+		{
+			{{Key: "$match", Value: bson.M{"receipts.delivered": true}}},
+			{{Key: "$unwind", Value: "$receipts"}},
+			{{Key: "$match", Value: bson.M{"receipts.delivered": true}}},
+			{{Key: "$group", Value: bson.M{
+				"_id":         bson.M{"conversationID": "$conversationID", "userID": "$receipts.userID"},
+				"deliveredID": bson.M{"$max": "$_id"},
+				"readID":      bson.M{"$max": bson.M{"$cond": bson.A{"$receipts.read", "$_id", nil}}},
+			}}},
+		},
+	}
+
+	migrated := 0
+	for _, pipeline := range pipelines {
+		cursor, err := db.Collection(collectionMessages).Aggregate(ctx, pipeline)
+		if err != nil {
+			return migrated, err
+		}
+
+		flags := []struct {
+			ID struct {
+				ConversationID string `bson:"conversationID"`
+				UserID         string `bson:"userID"`
+			} `bson:"_id"`
+			DeliveredID primitive.ObjectID  `bson:"deliveredID"`
+			ReadID      *primitive.ObjectID `bson:"readID"`
+		}{}
+		if err = cursor.All(ctx, &flags); err != nil {
+			return migrated, err
+		}
+
+		for _, flag := range flags {
+			updates := bson.M{"deliveredID": flag.DeliveredID}
+			if flag.ReadID != nil {
+				updates["readID"] = *flag.ReadID
+			}
+
+			_, err = db.Collection(collectionReceipts).UpdateOne(
+				ctx,
+				bson.M{"conversationID": flag.ID.ConversationID, "userID": flag.ID.UserID},
+				bson.M{
+					"$max": updates,
+					"$set": bson.M{"updatedAt": time.Now().UTC()},
+				},
+				options.Update().SetUpsert(true),
+			)
+			if err != nil {
+				return migrated, err
+			}
+			migrated++
+		}
+	}
+
+	_, err := db.Collection(collectionMessages).UpdateMany(
+		ctx,
+		bson.M{"$or": bson.A{
+			bson.M{"delivered": bson.M{"$exists": true}},
+			bson.M{"read": bson.M{"$exists": true}},
+			bson.M{"receipts": bson.M{"$exists": true}},
+		}},
+		bson.M{"$unset": bson.M{"delivered": "", "read": "", "receipts": ""}},
+	)
+	return migrated, err
+}
+
+// migrateEmailVerified marks the users who signed up before emails were verified as verified,
+// so their accounts keep working.
+func migrateEmailVerified(db *mongo.Database) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	result, err := db.Collection(collectionUsers).UpdateMany(
+		ctx,
+		bson.M{"emailVerified": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"emailVerified": true}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(result.ModifiedCount), nil
+}
+
+// migrateRefreshTokens removes the refresh token stored on every user.
+// Those tokens name neither their user nor a session, and tokens issued in the same second are identical,
+// so they can't be safely moved into sessions and their users have to sign in again.
+func migrateRefreshTokens(db *mongo.Database) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	result, err := db.Collection(collectionUsers).UpdateMany(
+		ctx,
+		bson.M{"refreshToken": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"refreshToken": ""}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(result.ModifiedCount), nil
+}
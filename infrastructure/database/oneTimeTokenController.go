@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/Mutay1/chat-backend/domain/repository"
+	"github.com/Mutay1/chat-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type OneTimeTokenController struct {
+	Db *mongo.Database
+}
+
+const collectionOneTimeTokens = "oneTimeTokens"
+
+// Create stores a token, revoking the tokens previously issued to the user for the same purpose.
+func (o OneTimeTokenController) Create(token models.OneTimeToken) (models.OneTimeToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := o.Db.Collection(collectionOneTimeTokens).DeleteMany(ctx, bson.M{
+		"userID":  token.UserID,
+		"purpose": token.Purpose,
+	})
+	if err != nil {
+		return models.OneTimeToken{}, err
+	}
+
+	if _, err = o.Db.Collection(collectionOneTimeTokens).InsertOne(ctx, token); err != nil {
+		return models.OneTimeToken{}, err
+	}
+
+	return token, nil
+}
+
+// GetLatest retrieves the token last issued to the user for the purpose, even if it has expired.
+// repository.ErrRecordNotFound is returned if no qualifying token is found.
+func (o OneTimeTokenController) GetLatest(userId string, purpose string) (models.OneTimeToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	foundToken := models.OneTimeToken{}
+	err := o.Db.Collection(collectionOneTimeTokens).FindOne(
+		ctx,
+		bson.M{"userID": userId, "purpose": purpose},
+		options.FindOne().SetSort(bson.M{"createdAt": -1}),
+	).Decode(&foundToken)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			return models.OneTimeToken{}, repository.ErrRecordNotFound
+
+		default:
+			return models.OneTimeToken{}, err
+		}
+	}
+
+	return foundToken, nil
+}
+
+// Consume atomically deletes the unexpired token with the given purpose and hash, returning it.
+// repository.ErrRecordNotFound is returned if no qualifying token is found.
+func (o OneTimeTokenController) Consume(purpose string, hash string) (models.OneTimeToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// expired tokens linger until the TTL monitor removes them, so they are filtered out here
+	foundToken := models.OneTimeToken{}
+	err := o.Db.Collection(collectionOneTimeTokens).FindOneAndDelete(ctx, bson.M{
+		"purpose":   purpose,
+		"hash":      hash,
+		"expiresAt": bson.M{"$gt": time.Now().UTC()},
+	}).Decode(&foundToken)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			return models.OneTimeToken{}, repository.ErrRecordNotFound
+
+		default:
+			return models.OneTimeToken{}, err
+		}
+	}
+
+	return foundToken, nil
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/Mutay1/chat-backend/domain/repository"
+	"github.com/Mutay1/chat-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type ReceiptController struct {
+	Db *mongo.Database
+}
+
+const collectionReceipts = "receipts"
+
+// GetByConversation retrieves the receipts of every user who has received messages in a conversation.
+func (r ReceiptController) GetByConversation(conversationId string) ([]models.Receipt, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := r.Db.Collection(collectionReceipts).Find(ctx, bson.M{"conversationID": conversationId})
+	if err != nil {
+		return nil, err
+	}
+
+	foundReceipts := []models.Receipt{}
+	if err = cursor.All(ctx, &foundReceipts); err != nil {
+		return nil, err
+	}
+
+	return foundReceipts, nil
+}
+
+// GetByUser retrieves the receipt of a user in a conversation.
+// repository.ErrRecordNotFound is returned if the user has not received any message yet.
+func (r ReceiptController) GetByUser(conversationId string, userId string) (models.Receipt, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// empty struct to populate with fetched receipt data
+	foundReceipt := models.Receipt{}
+
+	err := r.Db.Collection(collectionReceipts).FindOne(ctx, bson.M{
+		"conversationID": conversationId,
+		"userID":         userId,
+	}).Decode(&foundReceipt)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			return models.Receipt{}, repository.ErrRecordNotFound
+
+		default:
+			return models.Receipt{}, err
+		}
+	}
+
+	return foundReceipt, nil
+}
+
+// MarkDelivered moves the delivered cursor of a user forward to the message with the given ID.
+// Cursors never move backwards, so stale or reordered receipts are harmless.
+func (r ReceiptController) MarkDelivered(conversationId string, userId string, messageId string) error {
+	return r.advance(conversationId, userId, messageId, "deliveredID")
+}
+
+// MarkRead moves both the delivered and read cursors of a user forward to the message with the given ID.
+// Cursors never move backwards, so stale or reordered receipts are harmless.
+func (r ReceiptController) MarkRead(conversationId string, userId string, messageId string) error {
+	return r.advance(conversationId, userId, messageId, "deliveredID", "readID")
+}
+
+// advance moves the cursors forward, creating the receipt if needed.
+func (r ReceiptController) advance(conversationId string, userId string, messageId string, cursors ...string) error {
+	objectId, err := primitive.ObjectIDFromHex(messageId)
+	if err != nil {
+		return repository.ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	updates := bson.M{}
+	for _, cursor := range cursors {
+		updates[cursor] = objectId
+	}
+
+	_, err = r.Db.Collection(collectionReceipts).UpdateOne(
+		ctx,
+		bson.M{"conversationID": conversationId, "userID": userId},
+		bson.M{
+			"$max": updates,
+			"$set": bson.M{"updatedAt": time.Now().UTC()},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
@@ -0,0 +1,158 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/Mutay1/chat-backend/domain/repository"
+	"github.com/Mutay1/chat-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type SessionController struct {
+	Db *mongo.Database
+}
+
+const collectionSessions = "sessions"
+
+// Create stores a new session.
+func (s SessionController) Create(session models.Session) (models.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := s.Db.Collection(collectionSessions).InsertOne(ctx, session); err != nil {
+		return models.Session{}, err
+	}
+
+	return session, nil
+}
+
+// GetById retrieves an unexpired session via its ID.
+// repository.ErrRecordNotFound is returned if no qualifying session is found.
+func (s SessionController) GetById(id string) (models.Session, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Session{}, repository.ErrRecordNotFound
+	}
+
+	return s.findOne(bson.M{"_id": objectId})
+}
+
+// GetByUser retrieves the unexpired sessions of a user, most recently used first.
+func (s SessionController) GetByUser(userId string) ([]models.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := s.Db.Collection(collectionSessions).Find(
+		ctx,
+		bson.M{"userID": userId, "expiresAt": bson.M{"$gt": time.Now().UTC()}},
+		options.Find().SetSort(bson.M{"lastUsedAt": -1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	foundSessions := []models.Session{}
+	if err = cursor.All(ctx, &foundSessions); err != nil {
+		return nil, err
+	}
+
+	return foundSessions, nil
+}
+
+// Rotate replaces the refresh token of a session, provided the current one is still its latest,
+// and extends the session to the new token's expiry.
+// The check and the update are a single write, so a token can't be rotated twice concurrently.
+// repository.ErrRecordNotFound is returned if no qualifying session is found or its token was already rotated.
+func (s SessionController) Rotate(id string, currentHash string, newHash string, lastUsedAt time.Time, expiresAt time.Time) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return repository.ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := s.Db.Collection(collectionSessions).UpdateOne(
+		ctx,
+		bson.M{"_id": objectId, "refreshTokenHash": currentHash},
+		bson.M{"$set": bson.M{
+			"refreshTokenHash": newHash,
+			"lastUsedAt":       lastUsedAt,
+			"expiresAt":        expiresAt,
+		}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return repository.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Delete revokes a session of a user.
+// repository.ErrRecordNotFound is returned if no qualifying session is found.
+func (s SessionController) Delete(userId string, id string) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return repository.ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := s.Db.Collection(collectionSessions).DeleteOne(ctx, bson.M{"_id": objectId, "userID": userId})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return repository.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// DeleteByUser revokes every session of a user but the one with the given ID, if any.
+func (s SessionController) DeleteByUser(userId string, exceptId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"userID": userId}
+	if objectId, err := primitive.ObjectIDFromHex(exceptId); err == nil {
+		filter["_id"] = bson.M{"$ne": objectId}
+	}
+
+	_, err := s.Db.Collection(collectionSessions).DeleteMany(ctx, filter)
+	return err
+}
+
+// findOne retrieves the first unexpired session matching the filter.
+// repository.ErrRecordNotFound is returned if no qualifying session is found.
+func (s SessionController) findOne(filter bson.M) (models.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// expired sessions linger until the TTL monitor removes them, so they are filtered out here
+	filter["expiresAt"] = bson.M{"$gt": time.Now().UTC()}
+
+	foundSession := models.Session{}
+	err := s.Db.Collection(collectionSessions).FindOne(ctx, filter).Decode(&foundSession)
+	if err != nil {
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			return models.Session{}, repository.ErrRecordNotFound
+
+		default:
+			return models.Session{}, err
+		}
+	}
+
+	return foundSession, nil
+}
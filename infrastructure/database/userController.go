@@ -7,6 +7,7 @@ import (
 	"github.com/Mutay1/chat-backend/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"time"
 )
 
@@ -97,9 +98,9 @@ func (u UserController) GetByEmail(email string) (models.User, error) {
 	return foundUser, nil
 }
 
-// GetByRefreshToken retrieves an existing user via their refresh token.
+// GetByUsername retrieves an existing user via their username.
 // repository.ErrRecordNotFound is returned if no qualifying user is found.
-func (u UserController) GetByRefreshToken(refreshToken string) (models.User, error) {
+func (u UserController) GetByUsername(username string) (models.User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -107,7 +108,7 @@ func (u UserController) GetByRefreshToken(refreshToken string) (models.User, err
 	foundUser := models.User{}
 
 	err := u.Db.Collection(collectionUsers).FindOne(ctx, bson.M{
-		"refreshToken": refreshToken,
+		"username": username,
 	}).Decode(&foundUser)
 
 	if err != nil {
@@ -123,25 +124,106 @@ func (u UserController) GetByRefreshToken(refreshToken string) (models.User, err
 	return foundUser, nil
 }
 
-// UpdateRefreshToken resets the refresh token of the user with the given id.
-func (u UserController) UpdateRefreshToken(userId string, newRefreshToken string) error {
+// UpdateProfile overwrites the avatar, about, status and city of the given user.
+// The updated user is returned.
+// repository.ErrRecordNotFound is returned if no qualifying user is found.
+func (u UserController) UpdateProfile(user models.User) (models.User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	filter := bson.M{"userID": userId}
+	filter := bson.M{"userID": user.UserID}
 	updates := bson.M{
-		"refreshToken": newRefreshToken,
+		"avatarURL": user.AvatarURL,
+		"about":     user.About,
+		"status":    user.Status,
+		"city":      user.City,
+		"updatedAt": user.UpdatedAt,
 	}
 
-	_, err := u.Db.Collection(collectionUsers).UpdateOne(
+	// empty struct to populate with updated user data
+	updatedUser := models.User{}
+
+	err := u.Db.Collection(collectionUsers).FindOneAndUpdate(
 		ctx,
 		filter,
 		bson.M{"$set": updates},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updatedUser)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			return models.User{}, repository.ErrRecordNotFound
+
+		default:
+			return models.User{}, err
+		}
+	}
+
+	return updatedUser, nil
+}
+
+// VerifyEmail sets the email of the user with the given id, marking it as verified.
+// repository.ErrDuplicateDetails is returned if another user already has the email.
+// repository.ErrRecordNotFound is returned if no qualifying user is found.
+func (u UserController) VerifyEmail(userId string, email string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// the email may have been taken by another user since the verification was sent
+	count, err := u.Db.Collection(collectionUsers).CountDocuments(ctx, bson.M{
+		"email":  email,
+		"userID": bson.M{"$ne": userId},
+	})
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return repository.ErrDuplicateDetails
+	}
+
+	result, err := u.Db.Collection(collectionUsers).UpdateOne(
+		ctx,
+		bson.M{"userID": userId},
+		bson.M{"$set": bson.M{
+			"email":         email,
+			"emailVerified": true,
+			"updatedAt":     time.Now().UTC(),
+		}},
 	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return repository.ErrRecordNotFound
+	}
 
+	return nil
+}
+
+// UpdatePassword overwrites the hashed password of the user with the given id.
+// repository.ErrRecordNotFound is returned if no qualifying user is found.
+func (u UserController) UpdatePassword(userId string, password string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := u.Db.Collection(collectionUsers).UpdateOne(
+		ctx,
+		bson.M{"userID": userId},
+		bson.M{"$set": bson.M{
+			"password":  password,
+			"updatedAt": time.Now().UTC(),
+		}},
+	)
 	if err != nil {
 		return err
 	}
 
+	if result.MatchedCount == 0 {
+		return repository.ErrRecordNotFound
+	}
+
 	return nil
 }
@@ -0,0 +1,44 @@
+package file
+
+import (
+	"fmt"
+	"github.com/Mutay1/chat-backend/domain/mailer"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Mailer writes emails to a directory instead of sending them, for development.
+// Every email is saved in its own .eml file, which mail clients can open.
+type Mailer struct {
+	dir  string
+	from string
+	sent uint64
+}
+
+// NewMailer returns a mailer writing to the given directory, creating it if needed.
+func NewMailer(dir string, from string) (*Mailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &Mailer{dir: dir, from: from}, nil
+}
+
+// Send writes the message to a new file named after its date and recipient.
+func (m *Mailer) Send(message mailer.Message) error {
+	now := time.Now()
+
+	// recipients are user input, so only keep the characters safe in file names
+	recipient := strings.Map(func(r rune) rune {
+		if r == '@' || r == '.' || r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, message.To)
+
+	name := fmt.Sprintf("%s-%d-%s.eml", now.UTC().Format("20060102T150405"), atomic.AddUint64(&m.sent, 1), recipient)
+	return os.WriteFile(filepath.Join(m.dir, name), message.Format(m.from, now), 0o644)
+}
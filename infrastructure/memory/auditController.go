@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"github.com/Mutay1/chat-backend/models"
+	"sync"
+)
+
+type AuditController struct {
+	mu     sync.RWMutex
+	events []models.AuditEvent
+}
+
+// NewAuditController returns an empty in-memory audit repository.
+func NewAuditController() *AuditController {
+	return &AuditController{}
+}
+
+// Create records an audit event.
+func (a *AuditController) Create(event models.AuditEvent) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.events = append(a.events, event)
+	return nil
+}
+
+// Events returns the audit events of the user, oldest first, so tests can inspect them.
+func (a *AuditController) Events(userId string) []models.AuditEvent {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	events := []models.AuditEvent{}
+	for _, event := range a.events {
+		if event.UserID == userId {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
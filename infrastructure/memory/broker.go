@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"github.com/Mutay1/chat-backend/domain/broker"
+	"sync"
+)
+
+// Broker relays frames within a single process, for deployments running a single node.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[string]bool
+	queue       []broker.Delivery
+	closed      bool
+
+	// wake is signalled whenever the queue grows or the broker closes
+	wake       chan struct{}
+	deliveries chan broker.Delivery
+}
+
+// NewBroker returns a started in-process broker.
+func NewBroker() *Broker {
+	b := &Broker{
+		subscribers: make(map[string]bool),
+		wake:        make(chan struct{}, 1),
+		deliveries:  make(chan broker.Delivery),
+	}
+	go b.pump()
+
+	return b
+}
+
+// Publish queues the frame if the user is subscribed.
+// It never blocks, so the hub can publish frames it is itself the consumer of.
+func (b *Broker) Publish(userId string, frame []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed || !b.subscribers[userId] {
+		return nil
+	}
+
+	b.queue = append(b.queue, broker.Delivery{UserID: userId, Frame: frame})
+	b.signal()
+	return nil
+}
+
+func (b *Broker) Subscribe(userId string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscribers[userId] = true
+	return nil
+}
+
+func (b *Broker) Unsubscribe(userId string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subscribers, userId)
+	return nil
+}
+
+func (b *Broker) Deliveries() <-chan broker.Delivery {
+	return b.deliveries
+}
+
+// Close drops the queued frames and closes the deliveries channel.
+func (b *Broker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	b.signal()
+	return nil
+}
+
+// signal wakes the pump up, it must be called with the lock held.
+func (b *Broker) signal() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pump hands the queued frames over to the deliveries channel, in order.
+func (b *Broker) pump() {
+	defer close(b.deliveries)
+
+	for range b.wake {
+		for {
+			b.mu.Lock()
+			if b.closed {
+				b.mu.Unlock()
+				return
+			}
+			if len(b.queue) == 0 {
+				b.mu.Unlock()
+				break
+			}
+			delivery := b.queue[0]
+			b.queue = b.queue[1:]
+			b.mu.Unlock()
+
+			b.deliveries <- delivery
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package memory
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBrokerDeliversInOrderWithoutBlocking(t *testing.T) {
+	b := NewBroker()
+	defer b.Close()
+
+	b.Subscribe("alice")
+
+	// publishing never waits for the frames to be consumed
+	for _, frame := range []string{"first", "second", "third"} {
+		b.Publish("alice", []byte(frame))
+	}
+	b.Publish("bob", []byte("nobody listens"))
+
+	for _, expected := range []string{"first", "second", "third"} {
+		select {
+		case delivery := <-b.Deliveries():
+			if delivery.UserID != "alice" || string(delivery.Frame) != expected {
+				t.Fatalf("expected %q for alice, got %+v", expected, delivery)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %q before timeout", expected)
+		}
+	}
+
+	select {
+	case delivery := <-b.Deliveries():
+		t.Fatalf("expected no delivery for unsubscribed users, got %+v", delivery)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
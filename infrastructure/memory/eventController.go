@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"github.com/Mutay1/chat-backend/models"
+	"sync"
+)
+
+type EventController struct {
+	mu        sync.RWMutex
+	events    map[string][]models.Event
+	sequences map[string]int64
+}
+
+// NewEventController returns an empty in-memory event repository.
+func NewEventController() *EventController {
+	return &EventController{
+		events:    make(map[string][]models.Event),
+		sequences: make(map[string]int64),
+	}
+}
+
+// Append stores an event of a user, assigning it the next sequence number of that user.
+func (e *EventController) Append(event models.Event) (models.Event, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.sequences[event.UserID]++
+	event.Seq = e.sequences[event.UserID]
+	e.events[event.UserID] = append(e.events[event.UserID], event)
+
+	return event, nil
+}
+
+// GetAfter retrieves up to limit events of a user following the given sequence number, in order.
+func (e *EventController) GetAfter(userId string, seq int64, limit int64) ([]models.Event, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	foundEvents := []models.Event{}
+	for _, event := range e.events[userId] {
+		if int64(len(foundEvents)) == limit {
+			break
+		}
+		if event.Seq > seq {
+			foundEvents = append(foundEvents, event)
+		}
+	}
+
+	return foundEvents, nil
+}
+
+// LastSeq retrieves the sequence number of the latest event of a user, 0 if they have none.
+func (e *EventController) LastSeq(userId string) (int64, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.sequences[userId], nil
+}
@@ -0,0 +1,177 @@
+package memory
+
+import (
+	"github.com/Mutay1/chat-backend/domain/repository"
+	"github.com/Mutay1/chat-backend/models"
+	"sync"
+	"time"
+)
+
+type FriendshipController struct {
+	mu          sync.RWMutex
+	friendships []models.Friendship
+}
+
+// NewFriendshipController returns an empty in-memory friendship repository.
+func NewFriendshipController() *FriendshipController {
+	return &FriendshipController{}
+}
+
+// Create stores a new friend request.
+// repository.ErrDuplicateRecord is returned if a request already exists between both users, regardless of direction.
+func (f *FriendshipController) Create(friendship models.Friendship) (models.Friendship, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// check if any pre-existing request between both users exists
+	requesterId := friendship.Requester.ID.Hex()
+	recipientId := friendship.Recipient.ID.Hex()
+	for _, existingFriendship := range f.friendships {
+		if isPair(existingFriendship, requesterId, recipientId) {
+			return models.Friendship{}, repository.ErrDuplicateRecord
+		}
+	}
+
+	f.friendships = append(f.friendships, friendship)
+	return friendship, nil
+}
+
+// GetById retrieves an existing friendship via its ID.
+// repository.ErrRecordNotFound is returned if no qualifying friendship is found.
+func (f *FriendshipController) GetById(id string) (models.Friendship, error) {
+	return f.findOne(func(friendship models.Friendship) bool {
+		return friendship.ID.Hex() == id
+	})
+}
+
+// GetSent retrieves the pending requests sent by the user with the given id.
+func (f *FriendshipController) GetSent(userId string) ([]models.Friendship, error) {
+	return f.find(func(friendship models.Friendship) bool {
+		return !friendship.Accepted && friendship.Requester.ID.Hex() == userId
+	}), nil
+}
+
+// GetReceived retrieves the pending requests received by the user with the given id.
+func (f *FriendshipController) GetReceived(userId string) ([]models.Friendship, error) {
+	return f.find(func(friendship models.Friendship) bool {
+		return !friendship.Accepted && friendship.Recipient.ID.Hex() == userId
+	}), nil
+}
+
+// GetFriends retrieves the accepted friendships the user with the given id is part of.
+func (f *FriendshipController) GetFriends(userId string) ([]models.Friendship, error) {
+	return f.find(func(friendship models.Friendship) bool {
+		return friendship.Accepted && isMember(friendship, userId)
+	}), nil
+}
+
+// GetByPair retrieves the friendship between both users, regardless of who sent the request.
+// repository.ErrRecordNotFound is returned if no qualifying friendship is found.
+func (f *FriendshipController) GetByPair(userId string, otherUserId string) (models.Friendship, error) {
+	return f.findOne(func(friendship models.Friendship) bool {
+		return isPair(friendship, userId, otherUserId)
+	})
+}
+
+// Accept marks the pending request with the given id as accepted.
+// Only the recipient of a request can accept it.
+// repository.ErrRecordNotFound is returned if no qualifying request is found.
+func (f *FriendshipController) Accept(id string, recipientId string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for index, friendship := range f.friendships {
+		if friendship.ID.Hex() == id && friendship.Recipient.ID.Hex() == recipientId && !friendship.Accepted {
+			f.friendships[index].Accepted = true
+			f.friendships[index].UpdatedAt = time.Now().UTC()
+			return nil
+		}
+	}
+
+	return repository.ErrRecordNotFound
+}
+
+// Decline deletes the friendship with the given id.
+// Either member of a friendship can decline it.
+// repository.ErrRecordNotFound is returned if no qualifying friendship is found.
+func (f *FriendshipController) Decline(id string, userId string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for index, friendship := range f.friendships {
+		if friendship.ID.Hex() == id && isMember(friendship, userId) {
+			f.friendships = append(f.friendships[:index], f.friendships[index+1:]...)
+			return nil
+		}
+	}
+
+	return repository.ErrRecordNotFound
+}
+
+// UpdateMember refreshes the profile details of the given member across all their friendships.
+// The per-member flags are left untouched.
+func (f *FriendshipController) UpdateMember(member models.Friend) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for index := range f.friendships {
+		for _, side := range []*models.Friend{&f.friendships[index].Requester, &f.friendships[index].Recipient} {
+			if side.ID != member.ID {
+				continue
+			}
+
+			side.FirstName = member.FirstName
+			side.LastName = member.LastName
+			side.Username = member.Username
+			side.AvatarURL = member.AvatarURL
+			side.Status = member.Status
+			side.About = member.About
+			side.City = member.City
+		}
+	}
+
+	return nil
+}
+
+// findOne retrieves the first friendship satisfying the predicate.
+// repository.ErrRecordNotFound is returned if no qualifying friendship is found.
+func (f *FriendshipController) findOne(predicate func(friendship models.Friendship) bool) (models.Friendship, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for _, friendship := range f.friendships {
+		if predicate(friendship) {
+			return friendship, nil
+		}
+	}
+
+	return models.Friendship{}, repository.ErrRecordNotFound
+}
+
+// find retrieves all friendships satisfying the predicate.
+func (f *FriendshipController) find(predicate func(friendship models.Friendship) bool) []models.Friendship {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	foundFriendships := []models.Friendship{}
+	for _, friendship := range f.friendships {
+		if predicate(friendship) {
+			foundFriendships = append(foundFriendships, friendship)
+		}
+	}
+
+	return foundFriendships
+}
+
+// isMember reports whether the user is either member of the friendship.
+func isMember(friendship models.Friendship, userId string) bool {
+	return friendship.Requester.ID.Hex() == userId || friendship.Recipient.ID.Hex() == userId
+}
+
+// isPair reports whether the friendship is between both users, regardless of who sent the request.
+func isPair(friendship models.Friendship, userId string, otherUserId string) bool {
+	requesterId := friendship.Requester.ID.Hex()
+	recipientId := friendship.Recipient.ID.Hex()
+	return (requesterId == userId && recipientId == otherUserId) ||
+		(requesterId == otherUserId && recipientId == userId)
+}
@@ -0,0 +1,189 @@
+package memory
+
+import (
+	"github.com/Mutay1/chat-backend/domain/repository"
+	"github.com/Mutay1/chat-backend/models"
+	"sync"
+	"time"
+)
+
+type GroupController struct {
+	mu     sync.RWMutex
+	groups []models.Group
+}
+
+// NewGroupController returns an empty in-memory group repository.
+func NewGroupController() *GroupController {
+	return &GroupController{}
+}
+
+// Create stores a new group.
+func (g *GroupController) Create(group models.Group) (models.Group, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.groups = append(g.groups, copyGroup(group))
+	return group, nil
+}
+
+// GetById retrieves an existing group via its ID.
+// repository.ErrRecordNotFound is returned if no qualifying group is found.
+func (g *GroupController) GetById(id string) (models.Group, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	for _, group := range g.groups {
+		if group.ID.Hex() == id {
+			return copyGroup(group), nil
+		}
+	}
+
+	return models.Group{}, repository.ErrRecordNotFound
+}
+
+// GetByMember retrieves the groups the user with the given id is a member of.
+func (g *GroupController) GetByMember(userId string) ([]models.Group, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	foundGroups := []models.Group{}
+	for _, group := range g.groups {
+		if _, isMember := group.Member(userId); isMember {
+			foundGroups = append(foundGroups, copyGroup(group))
+		}
+	}
+
+	return foundGroups, nil
+}
+
+// UpdateDetails overwrites the name and avatar of the given group.
+// repository.ErrRecordNotFound is returned if no qualifying group is found.
+func (g *GroupController) UpdateDetails(group models.Group) error {
+	return g.update(group.ID.Hex(), func(storedGroup *models.Group) error {
+		storedGroup.Name = group.Name
+		storedGroup.AvatarURL = group.AvatarURL
+		storedGroup.UpdatedAt = group.UpdatedAt
+		return nil
+	})
+}
+
+// AddMember adds a new member to the group with the given id.
+// repository.ErrDuplicateRecord is returned if the user is already a member,
+// and repository.ErrRecordNotFound if no qualifying group is found.
+func (g *GroupController) AddMember(id string, member models.GroupMember) error {
+	return g.update(id, func(storedGroup *models.Group) error {
+		if _, isMember := storedGroup.Member(member.UserID); isMember {
+			return repository.ErrDuplicateRecord
+		}
+
+		storedGroup.Members = append(storedGroup.Members, member)
+		storedGroup.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+}
+
+// RemoveMember removes the user with the given id from the group.
+// repository.ErrRecordNotFound is returned if no qualifying group or member is found.
+func (g *GroupController) RemoveMember(id string, userId string) error {
+	return g.update(id, func(storedGroup *models.Group) error {
+		for index, member := range storedGroup.Members {
+			if member.UserID == userId {
+				storedGroup.Members = append(storedGroup.Members[:index], storedGroup.Members[index+1:]...)
+				storedGroup.UpdatedAt = time.Now().UTC()
+				return nil
+			}
+		}
+
+		return repository.ErrRecordNotFound
+	})
+}
+
+// Leave removes the member from the group with the given id, handing ownership to the successor with the given id, if any.
+// repository.ErrRecordNotFound is returned if no qualifying group is found,
+// or if the member no longer has the same role or the successor is no longer a member.
+func (g *GroupController) Leave(id string, member models.GroupMember, successorId string) error {
+	return g.update(id, func(storedGroup *models.Group) error {
+		storedMember, isMember := storedGroup.Member(member.UserID)
+		if !isMember || storedMember.Role != member.Role {
+			return repository.ErrRecordNotFound
+		}
+
+		successorIndex := -1
+		members := []models.GroupMember{}
+		for _, candidate := range storedGroup.Members {
+			if candidate.UserID == member.UserID {
+				continue
+			}
+			if candidate.UserID == successorId {
+				successorIndex = len(members)
+			}
+			members = append(members, candidate)
+		}
+
+		if successorId != "" {
+			if successorIndex < 0 {
+				return repository.ErrRecordNotFound
+			}
+			members[successorIndex].Role = models.GroupRoleOwner
+		}
+
+		storedGroup.Members = members
+		storedGroup.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+}
+
+// UpdateMemberRole sets the role of the user with the given id within the group.
+// repository.ErrRecordNotFound is returned if no qualifying group or member is found.
+func (g *GroupController) UpdateMemberRole(id string, userId string, role string) error {
+	return g.update(id, func(storedGroup *models.Group) error {
+		for index, member := range storedGroup.Members {
+			if member.UserID == userId {
+				storedGroup.Members[index].Role = role
+				storedGroup.UpdatedAt = time.Now().UTC()
+				return nil
+			}
+		}
+
+		return repository.ErrRecordNotFound
+	})
+}
+
+// Delete removes the group with the given id.
+// repository.ErrRecordNotFound is returned if no qualifying group is found.
+func (g *GroupController) Delete(id string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for index, group := range g.groups {
+		if group.ID.Hex() == id {
+			g.groups = append(g.groups[:index], g.groups[index+1:]...)
+			return nil
+		}
+	}
+
+	return repository.ErrRecordNotFound
+}
+
+// update applies the change to the stored group with the given id.
+// repository.ErrRecordNotFound is returned if no qualifying group is found.
+func (g *GroupController) update(id string, change func(storedGroup *models.Group) error) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for index := range g.groups {
+		if g.groups[index].ID.Hex() != id {
+			continue
+		}
+
+		return change(&g.groups[index])
+	}
+
+	return repository.ErrRecordNotFound
+}
+
+// copyGroup detaches the members of a group from the stored slice.
+func copyGroup(group models.Group) models.Group {
+	group.Members = append([]models.GroupMember{}, group.Members...)
+	return group
+}
@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Mutay1/chat-backend/domain/repository"
+	"github.com/Mutay1/chat-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGroupLeaveKeepsAnOwner(t *testing.T) {
+	g := NewGroupController()
+	owner := models.GroupMember{UserID: "owner", Role: models.GroupRoleOwner}
+	admin := models.GroupMember{UserID: "admin", Role: models.GroupRoleAdmin}
+	group, _ := g.Create(models.Group{ID: primitive.NewObjectID(), Members: []models.GroupMember{owner, admin}})
+	id := group.ID.Hex()
+
+	// the admin leaves while the owner hands them the group
+	if err := g.Leave(id, admin, ""); err != nil {
+		t.Fatalf("leave: %v", err)
+	}
+	if err := g.Leave(id, owner, admin.UserID); !errors.Is(err, repository.ErrRecordNotFound) {
+		t.Fatalf("expected leaving for a departed successor to fail, got %v", err)
+	}
+
+	// a member whose role changed since the group was read must read it again
+	_ = g.AddMember(id, models.GroupMember{UserID: "member", Role: models.GroupRoleMember})
+	if err := g.Leave(id, models.GroupMember{UserID: "member", Role: models.GroupRoleAdmin}, ""); !errors.Is(err, repository.ErrRecordNotFound) {
+		t.Fatalf("expected leaving with a stale role to fail, got %v", err)
+	}
+
+	if err := g.Leave(id, owner, "member"); err != nil {
+		t.Fatalf("leave as owner: %v", err)
+	}
+	group, _ = g.GetById(id)
+	if len(group.Members) != 1 || group.Members[0].UserID != "member" || group.Members[0].Role != models.GroupRoleOwner {
+		t.Fatalf("expected the member to be the sole owner, got %+v", group.Members)
+	}
+}
@@ -0,0 +1,13 @@
+package memory
+
+type HealthController struct{}
+
+// NewHealthController returns an in-memory health repository.
+func NewHealthController() *HealthController {
+	return &HealthController{}
+}
+
+// Ping never fails, as in-memory repositories are always available.
+func (h *HealthController) Ping() error {
+	return nil
+}
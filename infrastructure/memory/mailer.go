@@ -0,0 +1,41 @@
+package memory
+
+import (
+	"github.com/Mutay1/chat-backend/domain/mailer"
+	"sync"
+)
+
+// Mailer keeps the emails it is given instead of sending them, so tests can inspect them.
+type Mailer struct {
+	mu       sync.Mutex
+	messages []mailer.Message
+}
+
+// NewMailer returns a mailer that has sent nothing yet.
+func NewMailer() *Mailer {
+	return &Mailer{}
+}
+
+// Send records the message.
+func (m *Mailer) Send(message mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+// Sent returns the messages sent to the recipient, oldest first.
+func (m *Mailer) Sent(to string) []mailer.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sent := []mailer.Message{}
+	for _, message := range m.messages {
+		if message.To == to {
+			sent = append(sent, message)
+		}
+	}
+
+	return sent
+}
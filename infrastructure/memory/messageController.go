@@ -0,0 +1,142 @@
+package memory
+
+import (
+	"bytes"
+	"github.com/Mutay1/chat-backend/domain/repository"
+	"github.com/Mutay1/chat-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"sync"
+)
+
+type MessageController struct {
+	mu       sync.RWMutex
+	messages []models.Message
+}
+
+// NewMessageController returns an empty in-memory message repository.
+func NewMessageController() *MessageController {
+	return &MessageController{}
+}
+
+// Create stores a new message, assigning it an ID if it has none.
+// repository.ErrDuplicateRecord is returned if the sender already sent a message with the same client ID.
+func (m *MessageController) Create(message models.Message) (models.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if message.ClientID != "" {
+		for _, existing := range m.messages {
+			if existing.Sender == message.Sender && existing.ClientID == message.ClientID {
+				return models.Message{}, repository.ErrDuplicateRecord
+			}
+		}
+	}
+
+	if message.ID.IsZero() {
+		message.ID = primitive.NewObjectID()
+	}
+
+	m.messages = append(m.messages, message)
+	return message, nil
+}
+
+// GetById retrieves the message of a conversation with the given ID.
+// repository.ErrRecordNotFound is returned if no qualifying message is found.
+func (m *MessageController) GetById(conversationId string, id string) (models.Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, message := range m.messages {
+		if message.ConversationID == conversationId && message.ID.Hex() == id {
+			return message, nil
+		}
+	}
+
+	return models.Message{}, repository.ErrRecordNotFound
+}
+
+// GetByClientId retrieves the message a sender sent with the given client ID.
+// repository.ErrRecordNotFound is returned if no qualifying message is found.
+func (m *MessageController) GetByClientId(sender string, clientId string) (models.Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, message := range m.messages {
+		if message.Sender == sender && message.ClientID == clientId {
+			return message, nil
+		}
+	}
+
+	return models.Message{}, repository.ErrRecordNotFound
+}
+
+// GetByConversation retrieves up to limit messages of a conversation sent before the message with the given ID,
+// ordered from oldest to newest. An empty before starts from the latest message and a limit of 0 returns all of them.
+func (m *MessageController) GetByConversation(conversationId string, before string, limit int64) ([]models.Message, error) {
+	var beforeId primitive.ObjectID
+	if before != "" {
+		var err error
+		if beforeId, err = primitive.ObjectIDFromHex(before); err != nil {
+			return []models.Message{}, nil
+		}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	foundMessages := []models.Message{}
+	for _, message := range m.messages {
+		if message.ConversationID != conversationId {
+			continue
+		}
+		if before != "" && compareIds(message.ID, beforeId) >= 0 {
+			continue
+		}
+		foundMessages = append(foundMessages, message)
+	}
+
+	sort.Slice(foundMessages, func(i, j int) bool {
+		return compareIds(foundMessages[i].ID, foundMessages[j].ID) < 0
+	})
+
+	// keep the messages closest to the cursor
+	if limit > 0 && int64(len(foundMessages)) > limit {
+		foundMessages = foundMessages[int64(len(foundMessages))-limit:]
+	}
+
+	return foundMessages, nil
+}
+
+// CountUnread counts the messages of a conversation sent by others after the message with the given ID.
+// An empty readUpTo counts every message sent by others.
+func (m *MessageController) CountUnread(conversationId string, userId string, readUpTo string) (int64, error) {
+	var readUpToId primitive.ObjectID
+	if readUpTo != "" {
+		var err error
+		if readUpToId, err = primitive.ObjectIDFromHex(readUpTo); err != nil {
+			return 0, nil
+		}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var count int64
+	for _, message := range m.messages {
+		if message.ConversationID != conversationId || message.Sender == userId {
+			continue
+		}
+		if readUpTo != "" && compareIds(message.ID, readUpToId) <= 0 {
+			continue
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// compareIds orders ObjectIDs the same way MongoDB does.
+func compareIds(a primitive.ObjectID, b primitive.ObjectID) int {
+	return bytes.Compare(a[:], b[:])
+}
@@ -0,0 +1,65 @@
+package memory
+
+import (
+	"github.com/Mutay1/chat-backend/domain/repository"
+	"github.com/Mutay1/chat-backend/models"
+	"sync"
+	"time"
+)
+
+type OneTimeTokenController struct {
+	mu     sync.Mutex
+	tokens []models.OneTimeToken
+}
+
+// NewOneTimeTokenController returns an empty in-memory one-time token repository.
+func NewOneTimeTokenController() *OneTimeTokenController {
+	return &OneTimeTokenController{}
+}
+
+// Create stores a token, revoking the tokens previously issued to the user for the same purpose.
+func (o *OneTimeTokenController) Create(token models.OneTimeToken) (models.OneTimeToken, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	kept := o.tokens[:0]
+	for _, existingToken := range o.tokens {
+		if existingToken.UserID != token.UserID || existingToken.Purpose != token.Purpose {
+			kept = append(kept, existingToken)
+		}
+	}
+	o.tokens = append(kept, token)
+
+	return token, nil
+}
+
+// GetLatest retrieves the token last issued to the user for the purpose, even if it has expired.
+// repository.ErrRecordNotFound is returned if no qualifying token is found.
+func (o *OneTimeTokenController) GetLatest(userId string, purpose string) (models.OneTimeToken, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for index := len(o.tokens) - 1; index >= 0; index-- {
+		if o.tokens[index].UserID == userId && o.tokens[index].Purpose == purpose {
+			return o.tokens[index], nil
+		}
+	}
+
+	return models.OneTimeToken{}, repository.ErrRecordNotFound
+}
+
+// Consume atomically deletes the unexpired token with the given purpose and hash, returning it.
+// repository.ErrRecordNotFound is returned if no qualifying token is found.
+func (o *OneTimeTokenController) Consume(purpose string, hash string) (models.OneTimeToken, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for index, token := range o.tokens {
+		if token.Purpose == purpose && token.Hash == hash && token.ExpiresAt.After(time.Now()) {
+			o.tokens = append(o.tokens[:index], o.tokens[index+1:]...)
+			return token, nil
+		}
+	}
+
+	return models.OneTimeToken{}, repository.ErrRecordNotFound
+}
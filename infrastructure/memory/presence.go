@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"github.com/Mutay1/chat-backend/models"
+	"sync"
+	"time"
+)
+
+// Presence tracks the presence of users within a single process, for deployments running a single node.
+type Presence struct {
+	mu       sync.Mutex
+	sockets  map[string]int
+	statuses map[string]models.Presence
+}
+
+// NewPresence returns an in-process presence tracker with every user offline.
+func NewPresence() *Presence {
+	return &Presence{
+		sockets:  make(map[string]int),
+		statuses: make(map[string]models.Presence),
+	}
+}
+
+func (p *Presence) Connect(userId string) (models.Presence, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.sockets[userId]++
+	if p.sockets[userId] == 1 {
+		p.statuses[userId] = models.Presence{UserID: userId, Status: models.PresenceOnline}
+		return p.statuses[userId], true, nil
+	}
+
+	return p.statuses[userId], false, nil
+}
+
+func (p *Presence) Disconnect(userId string) (models.Presence, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.sockets[userId] > 1 {
+		p.sockets[userId]--
+		return p.statuses[userId], false, nil
+	}
+
+	delete(p.sockets, userId)
+	lastSeen := time.Now().UTC().Truncate(time.Second)
+	p.statuses[userId] = models.Presence{UserID: userId, Status: models.PresenceOffline, LastSeen: &lastSeen}
+
+	return p.statuses[userId], true, nil
+}
+
+func (p *Presence) SetStatus(userId string, status string) (models.Presence, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.sockets[userId] > 0 {
+		p.statuses[userId] = models.Presence{UserID: userId, Status: status}
+	}
+
+	return p.get(userId), nil
+}
+
+func (p *Presence) Get(userIds []string) ([]models.Presence, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	presences := make([]models.Presence, 0, len(userIds))
+	for _, userId := range userIds {
+		presences = append(presences, p.get(userId))
+	}
+
+	return presences, nil
+}
+
+// get returns the presence of a user, offline if they never connected.
+func (p *Presence) get(userId string) models.Presence {
+	presence, ok := p.statuses[userId]
+	if !ok {
+		return models.Presence{UserID: userId, Status: models.PresenceOffline}
+	}
+	return presence
+}
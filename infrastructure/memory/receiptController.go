@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"github.com/Mutay1/chat-backend/domain/repository"
+	"github.com/Mutay1/chat-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sync"
+	"time"
+)
+
+type ReceiptController struct {
+	mu       sync.RWMutex
+	receipts []models.Receipt
+}
+
+// NewReceiptController returns an empty in-memory receipt repository.
+func NewReceiptController() *ReceiptController {
+	return &ReceiptController{}
+}
+
+// GetByConversation retrieves the receipts of every user who has received messages in a conversation.
+func (r *ReceiptController) GetByConversation(conversationId string) ([]models.Receipt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	foundReceipts := []models.Receipt{}
+	for _, receipt := range r.receipts {
+		if receipt.ConversationID == conversationId {
+			foundReceipts = append(foundReceipts, receipt)
+		}
+	}
+
+	return foundReceipts, nil
+}
+
+// GetByUser retrieves the receipt of a user in a conversation.
+// repository.ErrRecordNotFound is returned if the user has not received any message yet.
+func (r *ReceiptController) GetByUser(conversationId string, userId string) (models.Receipt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, receipt := range r.receipts {
+		if receipt.ConversationID == conversationId && receipt.UserID == userId {
+			return receipt, nil
+		}
+	}
+
+	return models.Receipt{}, repository.ErrRecordNotFound
+}
+
+// MarkDelivered moves the delivered cursor of a user forward to the message with the given ID.
+// Cursors never move backwards, so stale or reordered receipts are harmless.
+func (r *ReceiptController) MarkDelivered(conversationId string, userId string, messageId string) error {
+	return r.advance(conversationId, userId, messageId, false)
+}
+
+// MarkRead moves both the delivered and read cursors of a user forward to the message with the given ID.
+// Cursors never move backwards, so stale or reordered receipts are harmless.
+func (r *ReceiptController) MarkRead(conversationId string, userId string, messageId string) error {
+	return r.advance(conversationId, userId, messageId, true)
+}
+
+// advance moves the cursors forward, creating the receipt if needed.
+func (r *ReceiptController) advance(conversationId string, userId string, messageId string, read bool) error {
+	objectId, err := primitive.ObjectIDFromHex(messageId)
+	if err != nil {
+		return repository.ErrRecordNotFound
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	index := -1
+	for i, receipt := range r.receipts {
+		if receipt.ConversationID == conversationId && receipt.UserID == userId {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		r.receipts = append(r.receipts, models.Receipt{ConversationID: conversationId, UserID: userId})
+		index = len(r.receipts) - 1
+	}
+
+	receipt := &r.receipts[index]
+	if compareIds(objectId, receipt.DeliveredID) > 0 {
+		receipt.DeliveredID = objectId
+	}
+	if read && compareIds(objectId, receipt.ReadID) > 0 {
+		receipt.ReadID = objectId
+	}
+	receipt.UpdatedAt = time.Now().UTC()
+
+	return nil
+}
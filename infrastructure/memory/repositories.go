@@ -0,0 +1,19 @@
+package memory
+
+import "github.com/Mutay1/chat-backend/domain/repository"
+
+// NewRepositories returns a full set of empty in-memory repositories.
+func NewRepositories() repository.Repositories {
+	return repository.Repositories{
+		Users:       NewUserController(),
+		Friendships: NewFriendshipController(),
+		Messages:    NewMessageController(),
+		Groups:      NewGroupController(),
+		Receipts:    NewReceiptController(),
+		Events:      NewEventController(),
+		Health:      NewHealthController(),
+		Tokens:      NewOneTimeTokenController(),
+		Audit:       NewAuditController(),
+		Sessions:    NewSessionController(),
+	}
+}
@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"github.com/Mutay1/chat-backend/domain/repository"
+	"github.com/Mutay1/chat-backend/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+type SessionController struct {
+	mu       sync.RWMutex
+	sessions []models.Session
+}
+
+// NewSessionController returns an empty in-memory session repository.
+func NewSessionController() *SessionController {
+	return &SessionController{}
+}
+
+// Create stores a new session.
+func (s *SessionController) Create(session models.Session) (models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions = append(s.sessions, session)
+	return session, nil
+}
+
+// GetById retrieves an unexpired session via its ID.
+// repository.ErrRecordNotFound is returned if no qualifying session is found.
+func (s *SessionController) GetById(id string) (models.Session, error) {
+	return s.findOne(func(session models.Session) bool {
+		return session.ID.Hex() == id
+	})
+}
+
+// GetByUser retrieves the unexpired sessions of a user, most recently used first.
+func (s *SessionController) GetByUser(userId string) ([]models.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	foundSessions := []models.Session{}
+	for _, session := range s.sessions {
+		if session.UserID == userId && session.ExpiresAt.After(time.Now()) {
+			foundSessions = append(foundSessions, session)
+		}
+	}
+
+	sort.SliceStable(foundSessions, func(i, j int) bool {
+		return foundSessions[i].LastUsedAt.After(foundSessions[j].LastUsedAt)
+	})
+
+	return foundSessions, nil
+}
+
+// Rotate replaces the refresh token of a session, provided the current one is still its latest,
+// and extends the session to the new token's expiry.
+// repository.ErrRecordNotFound is returned if no qualifying session is found or its token was already rotated.
+func (s *SessionController) Rotate(id string, currentHash string, newHash string, lastUsedAt time.Time, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for index := range s.sessions {
+		if s.sessions[index].ID.Hex() == id && s.sessions[index].RefreshTokenHash == currentHash {
+			s.sessions[index].RefreshTokenHash = newHash
+			s.sessions[index].LastUsedAt = lastUsedAt
+			s.sessions[index].ExpiresAt = expiresAt
+			return nil
+		}
+	}
+
+	return repository.ErrRecordNotFound
+}
+
+// Delete revokes a session of a user.
+// repository.ErrRecordNotFound is returned if no qualifying session is found.
+func (s *SessionController) Delete(userId string, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for index, session := range s.sessions {
+		if session.UserID == userId && session.ID.Hex() == id {
+			s.sessions = append(s.sessions[:index], s.sessions[index+1:]...)
+			return nil
+		}
+	}
+
+	return repository.ErrRecordNotFound
+}
+
+// DeleteByUser revokes every session of a user but the one with the given ID, if any.
+func (s *SessionController) DeleteByUser(userId string, exceptId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.sessions[:0]
+	for _, session := range s.sessions {
+		if session.UserID != userId || session.ID.Hex() == exceptId {
+			kept = append(kept, session)
+		}
+	}
+	s.sessions = kept
+
+	return nil
+}
+
+// findOne retrieves the first unexpired session satisfying the predicate.
+// repository.ErrRecordNotFound is returned if no qualifying session is found.
+func (s *SessionController) findOne(predicate func(session models.Session) bool) (models.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, session := range s.sessions {
+		if predicate(session) && session.ExpiresAt.After(time.Now()) {
+			return session, nil
+		}
+	}
+
+	return models.Session{}, repository.ErrRecordNotFound
+}
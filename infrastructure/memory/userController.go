@@ -0,0 +1,142 @@
+package memory
+
+import (
+	"github.com/Mutay1/chat-backend/domain/repository"
+	"github.com/Mutay1/chat-backend/models"
+	"sync"
+	"time"
+)
+
+type UserController struct {
+	mu    sync.RWMutex
+	users []models.User
+}
+
+// NewUserController returns an empty in-memory user repository.
+func NewUserController() *UserController {
+	return &UserController{}
+}
+
+// Create registers a new user, returning an error if a duplicate username or email is found.
+// repository.ErrDuplicateDetails is returned if at least the username or the email already exists.
+func (u *UserController) Create(user models.User) (models.User, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	// check if any pre-existing user with the same username or email exists
+	for _, existingUser := range u.users {
+		if equalStrings(existingUser.Username, user.Username) || equalStrings(existingUser.Email, user.Email) {
+			return models.User{}, repository.ErrDuplicateDetails
+		}
+	}
+
+	u.users = append(u.users, user)
+	return user, nil
+}
+
+// GetById retrieves an existing user via their ID.
+// repository.ErrRecordNotFound is returned if no qualifying user is found.
+func (u *UserController) GetById(id string) (models.User, error) {
+	return u.findOne(func(user models.User) bool {
+		return user.UserID == id
+	})
+}
+
+// GetByEmail retrieves an existing user via their email.
+// repository.ErrRecordNotFound is returned if no qualifying user is found.
+func (u *UserController) GetByEmail(email string) (models.User, error) {
+	return u.findOne(func(user models.User) bool {
+		return equalStrings(user.Email, &email)
+	})
+}
+
+// GetByUsername retrieves an existing user via their username.
+// repository.ErrRecordNotFound is returned if no qualifying user is found.
+func (u *UserController) GetByUsername(username string) (models.User, error) {
+	return u.findOne(func(user models.User) bool {
+		return equalStrings(user.Username, &username)
+	})
+}
+
+// UpdateProfile overwrites the avatar, about, status and city of the given user.
+// The updated user is returned.
+// repository.ErrRecordNotFound is returned if no qualifying user is found.
+func (u *UserController) UpdateProfile(user models.User) (models.User, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for index := range u.users {
+		if u.users[index].UserID == user.UserID {
+			u.users[index].AvatarURL = user.AvatarURL
+			u.users[index].About = user.About
+			u.users[index].Status = user.Status
+			u.users[index].City = user.City
+			u.users[index].UpdatedAt = user.UpdatedAt
+			return u.users[index], nil
+		}
+	}
+
+	return models.User{}, repository.ErrRecordNotFound
+}
+
+// VerifyEmail sets the email of the user with the given id, marking it as verified.
+// repository.ErrDuplicateDetails is returned if another user already has the email.
+// repository.ErrRecordNotFound is returned if no qualifying user is found.
+func (u *UserController) VerifyEmail(userId string, email string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, existingUser := range u.users {
+		if existingUser.UserID != userId && equalStrings(existingUser.Email, &email) {
+			return repository.ErrDuplicateDetails
+		}
+	}
+
+	for index := range u.users {
+		if u.users[index].UserID == userId {
+			u.users[index].Email = &email
+			u.users[index].EmailVerified = true
+			u.users[index].UpdatedAt = time.Now().UTC()
+			return nil
+		}
+	}
+
+	return repository.ErrRecordNotFound
+}
+
+// UpdatePassword overwrites the hashed password of the user with the given id.
+// repository.ErrRecordNotFound is returned if no qualifying user is found.
+func (u *UserController) UpdatePassword(userId string, password string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for index := range u.users {
+		if u.users[index].UserID == userId {
+			u.users[index].Password = &password
+			u.users[index].UpdatedAt = time.Now().UTC()
+			return nil
+		}
+	}
+
+	return repository.ErrRecordNotFound
+}
+
+// findOne retrieves the first user satisfying the predicate.
+// repository.ErrRecordNotFound is returned if no qualifying user is found.
+func (u *UserController) findOne(predicate func(user models.User) bool) (models.User, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	for _, user := range u.users {
+		if predicate(user) {
+			return user, nil
+		}
+	}
+
+	return models.User{}, repository.ErrRecordNotFound
+}
+
+// equalStrings reports whether both optional strings are set and equal.
+func equalStrings(a *string, b *string) bool {
+	return a != nil && b != nil && *a == *b
+}
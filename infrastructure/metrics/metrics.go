@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// namespace prefixes the name of every metric of the server.
+const namespace = "chat"
+
+// HTTP metrics, labelled by the route template rather than the path, to keep their cardinality bounded.
+var (
+	HttpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	HttpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to handle HTTP requests, by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+// WebSocket hub metrics, for the sockets connected to this node.
+var (
+	WsConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "websocket_connections",
+		Help:      "Sockets currently connected.",
+	})
+
+	WsUsers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "websocket_users",
+		Help:      "Users with at least one socket currently connected.",
+	})
+
+	WsEvictions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "websocket_evictions_total",
+		Help:      "Sockets disconnected by the server, by reason.",
+	}, []string{"reason"})
+)
+
+// Message metrics. Resent messages count as sent again but are only persisted once.
+var (
+	MessagesSent = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_sent_total",
+		Help:      "Messages sent by clients.",
+	})
+
+	MessagesPersisted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_persisted_total",
+		Help:      "Messages stored and fanned out to their conversation.",
+	})
+)
+
+// RepositoryDuration measures the calls to the repositories, by repository and method.
+var RepositoryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "repository_call_duration_seconds",
+	Help:      "Time taken by repository calls, by repository and method.",
+	Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+}, []string{"repository", "method"})
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"github.com/Mutay1/chat-backend/domain/repository"
+	"github.com/Mutay1/chat-backend/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// UserRepository measures the latency of every call to the user repository it wraps.
+type UserRepository struct {
+	Users repository.UserRepository
+}
+
+// observe starts timing a call to the given method, and records it once the returned function is called.
+func (u UserRepository) observe(method string) func() {
+	timer := prometheus.NewTimer(RepositoryDuration.WithLabelValues("users", method))
+	return func() { timer.ObserveDuration() }
+}
+
+func (u UserRepository) Create(user models.User) (models.User, error) {
+	defer u.observe("Create")()
+	return u.Users.Create(user)
+}
+
+func (u UserRepository) GetById(id string) (models.User, error) {
+	defer u.observe("GetById")()
+	return u.Users.GetById(id)
+}
+
+func (u UserRepository) GetByEmail(email string) (models.User, error) {
+	defer u.observe("GetByEmail")()
+	return u.Users.GetByEmail(email)
+}
+
+func (u UserRepository) GetByUsername(username string) (models.User, error) {
+	defer u.observe("GetByUsername")()
+	return u.Users.GetByUsername(username)
+}
+
+func (u UserRepository) UpdateProfile(user models.User) (models.User, error) {
+	defer u.observe("UpdateProfile")()
+	return u.Users.UpdateProfile(user)
+}
+
+func (u UserRepository) VerifyEmail(userId string, email string) error {
+	defer u.observe("VerifyEmail")()
+	return u.Users.VerifyEmail(userId, email)
+}
+
+func (u UserRepository) UpdatePassword(userId string, password string) error {
+	defer u.observe("UpdatePassword")()
+	return u.Users.UpdatePassword(userId, password)
+}
@@ -0,0 +1,93 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Mutay1/chat-backend/domain/broker"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestBroker connects a broker to the given embedded Redis server, as if it were another node.
+func newTestBroker(t *testing.T, server *miniredis.Miniredis) *Broker {
+	t.Helper()
+
+	b, err := NewBroker("redis://" + server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+
+	return b
+}
+
+// receive waits for the next delivery of the broker.
+func receive(t *testing.T, b *Broker) broker.Delivery {
+	t.Helper()
+
+	select {
+	case delivery := <-b.Deliveries():
+		return delivery
+	case <-time.After(5 * time.Second):
+		t.Fatal("no delivery before timeout")
+		return broker.Delivery{}
+	}
+}
+
+func TestBrokerRoutesBetweenNodes(t *testing.T) {
+	server := miniredis.RunT(t)
+	first := newTestBroker(t, server)
+	second := newTestBroker(t, server)
+
+	if err := first.Subscribe("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Subscribe("bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	// subscriptions are registered asynchronously
+	deadline := time.Now().Add(5 * time.Second)
+	for server.PubSubNumSub("chat:user:alice")["chat:user:alice"] == 0 || server.PubSubNumSub("chat:user:bob")["chat:user:bob"] == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("subscriptions not registered before timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// each frame only reaches the node holding the user
+	if err := second.Publish("alice", []byte("to alice")); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Publish("bob", []byte("to bob")); err != nil {
+		t.Fatal(err)
+	}
+
+	if delivery := receive(t, first); delivery.UserID != "alice" || string(delivery.Frame) != "to alice" {
+		t.Fatalf("expected the frame of alice on the first node, got %+v", delivery)
+	}
+	if delivery := receive(t, second); delivery.UserID != "bob" || string(delivery.Frame) != "to bob" {
+		t.Fatalf("expected the frame of bob on the second node, got %+v", delivery)
+	}
+
+	// and stops reaching it once unsubscribed
+	if err := first.Unsubscribe("alice"); err != nil {
+		t.Fatal(err)
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for server.PubSubNumSub("chat:user:alice")["chat:user:alice"] != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("unsubscription not registered before timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := second.Publish("alice", []byte("gone")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case delivery := <-first.Deliveries():
+		t.Fatalf("expected no delivery after unsubscribing, got %+v", delivery)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
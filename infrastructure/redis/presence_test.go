@@ -0,0 +1,57 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/Mutay1/chat-backend/models"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestPresence connects a presence tracker to the given embedded Redis server, as if it were another node.
+func newTestPresence(t *testing.T, server *miniredis.Miniredis) *Presence {
+	t.Helper()
+
+	p, err := NewPresence("redis://" + server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+
+	return p
+}
+
+func TestPresenceAcrossNodes(t *testing.T) {
+	server := miniredis.RunT(t)
+	first := newTestPresence(t, server)
+	second := newTestPresence(t, server)
+
+	if presences, err := first.Get([]string{"alice"}); err != nil || presences[0].Status != models.PresenceOffline || presences[0].LastSeen != nil {
+		t.Fatalf("expected users who never connected to be offline, got %+v, %v", presences, err)
+	}
+
+	if presence, isFirst, err := first.Connect("alice"); err != nil || !isFirst || presence.Status != models.PresenceOnline {
+		t.Fatalf("expected the first socket to bring alice online, got %+v, %v, %v", presence, isFirst, err)
+	}
+	if _, isFirst, err := second.Connect("alice"); err != nil || isFirst {
+		t.Fatalf("expected a socket on another node not to be the first, got %v, %v", isFirst, err)
+	}
+
+	if presence, err := second.SetStatus("alice", models.PresenceAway); err != nil || presence.Status != models.PresenceAway {
+		t.Fatalf("expected alice to be away, got %+v, %v", presence, err)
+	}
+
+	// alice stays online while connected to any node
+	if presence, last, err := first.Disconnect("alice"); err != nil || last || presence.Status != models.PresenceAway {
+		t.Fatalf("expected alice to stay away while connected to the second node, got %+v, %v, %v", presence, last, err)
+	}
+	if presences, err := first.Get([]string{"alice"}); err != nil || presences[0].Status != models.PresenceAway {
+		t.Fatalf("expected the first node to see alice away, got %+v, %v", presences, err)
+	}
+
+	if presence, last, err := second.Disconnect("alice"); err != nil || !last || presence.Status != models.PresenceOffline || presence.LastSeen == nil {
+		t.Fatalf("expected the last socket to take alice offline, got %+v, %v, %v", presence, last, err)
+	}
+	if presence, err := first.SetStatus("alice", models.PresenceOnline); err != nil || presence.Status != models.PresenceOffline {
+		t.Fatalf("expected disconnected users to stay offline, got %+v, %v", presence, err)
+	}
+}
@@ -0,0 +1,34 @@
+package smtp
+
+import (
+	"fmt"
+	"github.com/Mutay1/chat-backend/domain/mailer"
+	"net/smtp"
+	"time"
+)
+
+// Mailer sends emails through an SMTP server.
+type Mailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewMailer returns a mailer sending from the given address through the SMTP server at host:port.
+// The username and password are only used if a username is given.
+func NewMailer(host string, port int, username string, password string, from string) *Mailer {
+	m := &Mailer{
+		addr: fmt.Sprintf("%s:%d", host, port),
+		from: from,
+	}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return m
+}
+
+// Send delivers the message to the SMTP server, upgrading to TLS when the server supports it.
+func (m *Mailer) Send(message mailer.Message) error {
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{message.To}, message.Format(m.from, time.Now()))
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Security-sensitive actions recorded in the audit log.
+const (
+	AuditPasswordChanged      = "password_changed"
+	AuditPasswordReset        = "password_reset"
+	AuditEmailChangeRequested = "email_change_requested"
+	AuditEmailChanged         = "email_changed"
+	AuditRefreshTokenReused   = "refresh_token_reused"
+)
+
+// AuditEvent records a security-sensitive action taken on an account, and where it came from.
+type AuditEvent struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	UserID    string             `json:"userID" bson:"userID"`
+	Action    string             `json:"action" bson:"action"`
+	IP        string             `json:"ip" bson:"ip"`
+	UserAgent string             `json:"userAgent" bson:"userAgent"`
+	Details   map[string]string  `json:"details,omitempty" bson:"details,omitempty"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
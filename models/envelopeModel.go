@@ -0,0 +1,156 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ProtocolVersion is the latest version of the WebSocket protocol.
+// Clients pick a version with the "chat.v<version>" subprotocol or the "version" query parameter,
+// defaulting to the latest one, and must then tag every frame they send with it.
+const ProtocolVersion = 1
+
+// Envelope wraps every frame exchanged over the WebSocket.
+// ID is chosen by the client for the frames it sends and echoed in the error frames they cause,
+// while server events carry the ID of the entity they describe, if any.
+// Seq numbers the message.new and receipt frames of each user, so clients can resume from the last one they saw.
+// Frames published from different nodes may arrive out of order, so clients should resume from
+// the highest Seq up to which they saw every frame, and ignore the frames they already saw when replayed.
+type Envelope struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Version int             `json:"version"`
+	Seq     int64           `json:"seq,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Frames sent by clients.
+const (
+	// EventAuth carries an AuthPayload, as the first frame of sockets opened without a token.
+	EventAuth = "auth"
+	// EventMessageSend carries a SendMessagePayload.
+	// Its ID is generated by the client and required, so that resending the frame never duplicates the message.
+	EventMessageSend = "message.send"
+	// EventReceiptUpdate carries a ReceiptUpdatePayload.
+	EventReceiptUpdate = "receipt.update"
+	// EventPresenceUpdate carries a PresenceUpdatePayload.
+	EventPresenceUpdate = "presence.update"
+	// EventResume carries a ResumePayload, to replay the frames missed while disconnected.
+	EventResume = "resume"
+)
+
+// Frames sent by clients and relayed to the other members of the conversation.
+const (
+	// EventTypingStart carries a TypingPayload.
+	EventTypingStart = "typing.start"
+	// EventTypingStop carries a TypingPayload.
+	EventTypingStop = "typing.stop"
+)
+
+// Frames sent by the server.
+const (
+	// EventWelcome carries a WelcomePayload, once the socket is authenticated.
+	EventWelcome = "welcome"
+	// EventMessageNew carries the Message, to every member of its conversation.
+	EventMessageNew = "message.new"
+	// EventReceipt carries a ReceiptPayload, to every member of its conversation.
+	EventReceipt = "receipt"
+	// EventPresence carries the Presence of a user, to their friends.
+	EventPresence = "presence"
+	// EventResumed carries a ResumePayload, once every missed frame has been replayed.
+	EventResumed = "resumed"
+	// EventResync carries a ResumePayload, when missed frames cannot be replayed.
+	// Clients should then refetch their conversations over HTTP and carry on from the given sequence number.
+	EventResync = "resync"
+	// EventAck carries an AckPayload, to the socket that sent the message.send frame with the same ID.
+	EventAck = "ack"
+	// EventNack carries a NackPayload, to the socket that sent the message.send frame with the same ID.
+	EventNack = "nack"
+	// EventError carries an ErrorPayload, to the socket that sent the offending frame.
+	EventError = "error"
+	// EventSessionRevoked carries no payload and the ID of the revoked session, or none if every session of the user was revoked.
+	// It is sent to every socket of the user, and the sockets of the revoked sessions are then closed with a policy violation.
+	EventSessionRevoked = "session.revoked"
+)
+
+// Error codes of error and nack frames.
+const (
+	ErrorInvalidFrame         = "invalid_frame"
+	ErrorUnsupportedVersion   = "unsupported_version"
+	ErrorUnknownType          = "unknown_type"
+	ErrorInvalidPayload       = "invalid_payload"
+	ErrorConversationNotFound = "conversation_not_found"
+	ErrorMessageNotFound      = "message_not_found"
+	ErrorInternal             = "internal_error"
+)
+
+// Conversation addresses either a direct conversation with a friend or a group conversation.
+type Conversation struct {
+	RecipientID string `json:"recipientID,omitempty" validate:"required_without=GroupID,excluded_with=GroupID"`
+	GroupID     string `json:"groupID,omitempty"`
+}
+
+type AuthPayload struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type SendMessagePayload struct {
+	Conversation
+	Content string `json:"content" validate:"required"`
+}
+
+// ReceiptUpdatePayload marks every message up to MessageID as delivered or read.
+// An empty MessageID stands for the latest message of the conversation.
+type ReceiptUpdatePayload struct {
+	Conversation
+	MessageID string `json:"messageID,omitempty"`
+	Status    string `json:"status" validate:"required,oneof=delivered read"`
+}
+
+type PresenceUpdatePayload struct {
+	Status string `json:"status" validate:"required,oneof=online away"`
+}
+
+type TypingPayload struct {
+	Conversation
+	ConversationID string `json:"conversationID,omitempty"`
+	UserID         string `json:"userID,omitempty"`
+}
+
+// WelcomePayload carries the sequence number of the latest frame of the user,
+// which new clients resume from once they have fetched their conversations.
+type WelcomePayload struct {
+	UserID  string `json:"userID"`
+	Version int    `json:"version"`
+	LastSeq int64  `json:"lastSeq"`
+}
+
+type ResumePayload struct {
+	LastSeq int64 `json:"lastSeq" validate:"min=0"`
+}
+
+type ReceiptPayload struct {
+	ConversationID string `json:"conversationID"`
+	UserID         string `json:"userID"`
+	MessageID      string `json:"messageID"`
+	Status         string `json:"status"`
+}
+
+// AckPayload confirms a message was stored, and is sent again whenever the message is resent.
+type AckPayload struct {
+	MessageID      string    `json:"messageID"`
+	ConversationID string    `json:"conversationID"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// NackPayload reports why a message was not stored.
+// The message can be resent with the same ID once the reason is addressed.
+type NackPayload struct {
+	Code   string `json:"code"`
+	Reason string `json:"reason"`
+}
+
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
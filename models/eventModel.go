@@ -0,0 +1,17 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event is a frame delivered to a user, kept so reconnecting clients can catch up on what they missed.
+// Seq increases by one with every event of the user.
+type Event struct {
+	UserID    string          `json:"userID" bson:"userID"`
+	Seq       int64           `json:"seq" bson:"seq"`
+	Type      string          `json:"type" bson:"type"`
+	FrameID   string          `json:"frameID,omitempty" bson:"frameID,omitempty"`
+	Payload   json.RawMessage `json:"payload" bson:"payload"`
+	CreatedAt time.Time       `json:"createdAt" bson:"createdAt"`
+}
@@ -26,20 +26,11 @@ type Friend struct {
 	Status    string             `json:"status" bson:"status"`
 	About     string             `json:"about" bson:"about"`
 	City      string             `json:"city" bson:"city"`
-	Messages  []Message          `json:"messages" bson:"messages"`
 	Archived  bool               `json:"archived,omitempty" bson:"archived"`
 	Favorite  bool               `json:"favorite,omitempty" bson:"favorite"`
 	Blocked   bool               `json:"blocked,omitempty" bson:"blocked"`
-}
 
-// Message is return msg
-type Message struct {
-	Sender      string    `json:"sender,omitempty" bson:"sender"`
-	RecipientID string    `json:"recipientID" bson:"recipientID"`
-	Content     string    `json:"content,omitempty" bson:"content"`
-	Delivered   bool      `json:"delivered" bson:"delivered"`
-	Read        bool      `json:"read" bson:"read"`
-	CreatedAt   time.Time `json:"createdAt,omitempty" bson:"createdAt"`
-	MessageType string    `json:"messageType"`
-	UpdateType  string    `json:"updateType"`
+	// conversation summary, populated when listing friends
+	LastMessage *Message `json:"lastMessage" bson:"-"`
+	UnreadCount int64    `json:"unreadCount" bson:"-"`
 }
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Roles a member can hold within a group, from most to least privileged.
+const (
+	GroupRoleOwner  = "owner"
+	GroupRoleAdmin  = "admin"
+	GroupRoleMember = "member"
+)
+
+// Group is a conversation between any number of members
+type Group struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	Name      string             `json:"name" bson:"name"`
+	AvatarURL string             `json:"avatarURL" bson:"avatarURL"`
+	Members   []GroupMember      `json:"members" bson:"members"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
+
+	// conversation summary, populated when listing groups
+	LastMessage *Message `json:"lastMessage,omitempty" bson:"-"`
+	UnreadCount int64    `json:"unreadCount" bson:"-"`
+}
+
+// GroupMember is a user's membership of a group
+type GroupMember struct {
+	UserID   string    `json:"userID" bson:"userID"`
+	Role     string    `json:"role" bson:"role"`
+	JoinedAt time.Time `json:"joinedAt" bson:"joinedAt"`
+}
+
+// Member retrieves the membership of the user with the given id, if any.
+func (g Group) Member(userId string) (GroupMember, bool) {
+	for _, member := range g.Members {
+		if member.UserID == userId {
+			return member, true
+		}
+	}
+	return GroupMember{}, false
+}
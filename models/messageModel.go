@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Message is return msg
+type Message struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id"`
+	ConversationID string             `json:"conversationID" bson:"conversationID"`
+	Sender         string             `json:"sender,omitempty" bson:"sender"`
+	ClientID       string             `json:"clientID,omitempty" bson:"clientID,omitempty"`
+	RecipientID    string             `json:"recipientID" bson:"recipientID"`
+	GroupID        string             `json:"groupID,omitempty" bson:"groupID,omitempty"`
+	Content        string             `json:"content,omitempty" bson:"content"`
+	CreatedAt      time.Time          `json:"createdAt,omitempty" bson:"createdAt"`
+}
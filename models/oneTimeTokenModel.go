@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Purposes a one-time token can be issued for.
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+)
+
+// OneTimeToken is a single-use secret mailed to a user, stored only as a hash.
+// Email is the address the token was sent to, which an email verification token confirms.
+type OneTimeToken struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	UserID    string             `json:"userID" bson:"userID"`
+	Purpose   string             `json:"purpose" bson:"purpose"`
+	Hash      string             `json:"-" bson:"hash"`
+	Email     string             `json:"email" bson:"email"`
+	ExpiresAt time.Time          `json:"expiresAt" bson:"expiresAt"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
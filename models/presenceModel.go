@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+const (
+	PresenceOnline  = "online"
+	PresenceAway    = "away"
+	PresenceOffline = "offline"
+)
+
+// Presence is the connection status of a user.
+// LastSeen is set once the user has disconnected all of their sockets.
+type Presence struct {
+	UserID   string     `json:"userID"`
+	Status   string     `json:"status"`
+	LastSeen *time.Time `json:"lastSeen"`
+}
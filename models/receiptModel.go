@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Receipt tracks how far a user has received and read a conversation.
+// Every message up to and including the cursor's message is considered delivered or read.
+type Receipt struct {
+	ConversationID string             `json:"conversationID" bson:"conversationID"`
+	UserID         string             `json:"userID" bson:"userID"`
+	DeliveredID    primitive.ObjectID `json:"deliveredID" bson:"deliveredID"`
+	ReadID         primitive.ObjectID `json:"readID" bson:"readID"`
+	UpdatedAt      time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is a device a user is signed in on.
+// It is the family of the refresh tokens issued to that device, each replacing the one it was refreshed with,
+// so only the hash of the latest is kept. Its ID is the family ID carried by each of them.
+type Session struct {
+	ID               primitive.ObjectID `json:"id" bson:"_id"`
+	UserID           string             `json:"-" bson:"userID"`
+	RefreshTokenHash string             `json:"-" bson:"refreshTokenHash"`
+	DeviceName       string             `json:"deviceName" bson:"deviceName"`
+	UserAgent        string             `json:"userAgent" bson:"userAgent"`
+	IP               string             `json:"ip" bson:"ip"`
+	CreatedAt        time.Time          `json:"createdAt" bson:"createdAt"`
+	LastUsedAt       time.Time          `json:"lastUsedAt" bson:"lastUsedAt"`
+	ExpiresAt        time.Time          `json:"expiresAt" bson:"expiresAt"`
+}